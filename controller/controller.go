@@ -3,7 +3,10 @@ package controller
 import (
 	"time"
 
-	"github.com/niusmallnan/kube-rdns/controller/rdns"
+	"github.com/niusmallnan/kube-rdns/controller/k8s"
+	"github.com/niusmallnan/kube-rdns/controller/notify"
+	"github.com/niusmallnan/kube-rdns/controller/provider"
+	"github.com/niusmallnan/kube-rdns/controller/utils"
 	"github.com/niusmallnan/kube-rdns/controller/watch"
 	"github.com/niusmallnan/kube-rdns/setting"
 	"github.com/sirupsen/logrus"
@@ -20,18 +23,24 @@ const (
 )
 
 type RDNSController struct {
-	rdnsClient *rdns.Client
-	kubeClient *kubernetes.Clientset
+	provider   provider.Provider
+	syncer     *provider.Syncer
+	kubeClient kubernetes.Interface
 	ingRes     *watch.IngressResource
+	// started is closed once the startup delay is over
+	started chan struct{}
 }
 
-func NewRDNSController(kubeClient *kubernetes.Clientset) *RDNSController {
-	rdnsClient := rdns.NewClient(kubeClient)
-	ingRes := watch.NewIngressResource(kubeClient, rdnsClient)
+func NewRDNSController(kubeClient kubernetes.Interface, p provider.Provider) *RDNSController {
+	// the controller and the ingress watcher share the syncer, which notifies every apply
+	syncer := provider.NewSyncer(p, notify.NewNotifier(setting.GetNotifyURL(), setting.IsNotifySlack(), setting.IsNotifyNoop()))
+	ingRes := watch.NewIngressResource(kubeClient, syncer)
 	return &RDNSController{
-		rdnsClient: rdnsClient,
+		provider:   p,
+		syncer:     syncer,
 		kubeClient: kubeClient,
 		ingRes:     ingRes,
+		started:    make(chan struct{}),
 	}
 }
 
@@ -40,13 +49,15 @@ func (c *RDNSController) Stop() error {
 }
 
 func (c *RDNSController) Start() {
+	c.waitStartupDelay()
+
 	ips, err := c.getNginxControllerIPs()
 	if err != nil {
 		logrus.Fatalf("Fail to get nginx controller ips on start(), err: %s", err)
 	}
 
 	logrus.Infof("Got the host ips: %s", ips)
-	if err = c.rdnsClient.ApplyDomain(ips); err != nil {
+	if _, err = c.syncer.Apply(ips); err != nil && !provider.IsSkipped(err) {
 		logrus.Error(err)
 	}
 
@@ -57,12 +68,25 @@ func (c *RDNSController) Start() {
 	select {}
 }
 
+// waitStartupDelay sleeps for the startup delay, reconciles are refused until it returns
+func (c *RDNSController) waitStartupDelay() {
+	if delay := setting.GetStartupDelay(); delay > 0 {
+		logrus.Infof("Waiting %s before the first sync", delay.String())
+		time.Sleep(delay)
+	}
+	close(c.started)
+}
+
 func (c *RDNSController) renewLoop() {
 	logrus.Infof("Running renew loop with duration: %s", setting.GetRenewDuration().String())
 	ticker := time.NewTicker(setting.GetRenewDuration())
 	for t := range ticker.C {
 		logrus.Infof("Tick at %s", t.String())
-		if err := c.rdnsClient.RenewDomain(); err != nil {
+		if err := c.provider.Renew(); err != nil {
+			if provider.IsSkipped(err) {
+				logrus.Debugf("Skip renewing domain: %v", err)
+				continue
+			}
 			logrus.Errorf("Failed to renew domain: %v", err)
 			continue
 		}
@@ -70,10 +94,14 @@ func (c *RDNSController) renewLoop() {
 }
 
 func (c *RDNSController) getNginxControllerIPs() ([]string, error) {
+	if svc := setting.GetControllerService(); svc != "" {
+		return k8s.GetServiceIPs(c.kubeClient, svc)
+	}
+
 	var ips []string
 
 	options := metav1.ListOptions{LabelSelector: labels.SelectorFromSet(labels.Set{"app": podNginxControllerLabel}).String()}
-	pods, err := c.kubeClient.CoreV1().Pods(defaultNginxIngressNamespace).List(options)
+	pods, err := k8s.ListPods(c.kubeClient, defaultNginxIngressNamespace, options)
 
 	if err != nil {
 		logrus.WithError(err).Error("syncing ingress rules to rdns server error")
@@ -85,6 +113,10 @@ func (c *RDNSController) getNginxControllerIPs() ([]string, error) {
 			logrus.WithError(err).Errorf("get node %s public ip error", pod.Spec.NodeName)
 			continue
 		}
+		if ip, err = utils.HostIP(ip, setting.IsGlobalIPsOnly()); err != nil {
+			logrus.Debugf("Node %s: skip %v", pod.Spec.NodeName, err)
+			continue
+		}
 		ips = append(ips, ip)
 	}
 	return ips, nil
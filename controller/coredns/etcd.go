@@ -0,0 +1,130 @@
+package coredns
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// etcdClient talks to the etcd v3 JSON gateway, so that no etcd client library is needed
+type etcdClient struct {
+	httpClient *http.Client
+	endpoint   string
+}
+
+type keyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+type rangeRequest struct {
+	Key      string `json:"key"`
+	RangeEnd string `json:"range_end,omitempty"`
+}
+
+type rangeResponse struct {
+	Kvs []keyValue `json:"kvs"`
+}
+
+type requestOp struct {
+	RequestPut         *keyValue     `json:"request_put,omitempty"`
+	RequestDeleteRange *rangeRequest `json:"request_delete_range,omitempty"`
+}
+
+type txnRequest struct {
+	Success []requestOp `json:"success"`
+}
+
+func encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func decode(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	return string(b), err
+}
+
+// prefixEnd returns the range end which covers all keys with the prefix
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return "\x00"
+}
+
+func (c *etcdClient) post(path string, payload, out interface{}) error {
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(payload); err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Post(fmt.Sprintf("%s%s", c.endpoint, path), "application/json", buf)
+	if err != nil {
+		return err
+	}
+	// when err is nil, resp contains a non-nil resp.Body which must be closed
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "Read response body error")
+	}
+	if code := resp.StatusCode; code < 200 || code >= 300 {
+		return errors.Errorf("Got etcd error: %d %s", code, body)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return errors.Wrap(json.Unmarshal(body, out), "Decode response error")
+}
+
+// getPrefix returns all keys with the prefix and their values
+func (c *etcdClient) getPrefix(prefix string) (map[string]string, error) {
+	var resp rangeResponse
+	req := &rangeRequest{Key: encode(prefix), RangeEnd: encode(prefixEnd(prefix))}
+	if err := c.post("/v3/kv/range", req, &resp); err != nil {
+		return nil, err
+	}
+
+	kvs := make(map[string]string, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		key, err := decode(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := decode(kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		kvs[key] = value
+	}
+
+	return kvs, nil
+}
+
+// replace deletes the keys and puts the kvs in one transaction
+func (c *etcdClient) replace(deletes []string, puts map[string]string) error {
+	txn := &txnRequest{}
+	for _, key := range deletes {
+		// etcd refuses a transaction which touches the same key twice
+		if _, ok := puts[key]; ok {
+			continue
+		}
+		txn.Success = append(txn.Success, requestOp{RequestDeleteRange: &rangeRequest{Key: encode(key)}})
+	}
+	for key, value := range puts {
+		txn.Success = append(txn.Success, requestOp{RequestPut: &keyValue{Key: encode(key), Value: encode(value)}})
+	}
+
+	return c.post("/v3/kv/txn", txn, nil)
+}
@@ -0,0 +1,164 @@
+package coredns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// fakeEtcd is a minimal etcd v3 JSON gateway, it serves range and txn requests from a map
+type fakeEtcd struct {
+	sync.Mutex
+	kvs  map[string]string
+	txns int
+}
+
+func newFakeEtcd(kvs map[string]string) (*fakeEtcd, *etcdClient, func()) {
+	f := &fakeEtcd{kvs: kvs}
+	if f.kvs == nil {
+		f.kvs = map[string]string{}
+	}
+	srv := httptest.NewServer(f)
+	return f, &etcdClient{httpClient: http.DefaultClient, endpoint: srv.URL}, srv.Close
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	switch req.URL.Path {
+	case "/v3/kv/range":
+		var r rangeRequest
+		if err := json.NewDecoder(req.Body).Decode(&r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		start, _ := decode(r.Key)
+		end, _ := decode(r.RangeEnd)
+		resp := rangeResponse{}
+		for key, value := range f.kvs {
+			if key == start || (end != "" && key >= start && key < end) {
+				resp.Kvs = append(resp.Kvs, keyValue{Key: encode(key), Value: encode(value)})
+			}
+		}
+		json.NewEncoder(w).Encode(&resp)
+	case "/v3/kv/txn":
+		var txn txnRequest
+		if err := json.NewDecoder(req.Body).Decode(&txn); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// like etcd, refuse a transaction which touches the same key twice
+		seen := map[string]bool{}
+		for _, op := range txn.Success {
+			key := ""
+			if op.RequestPut != nil {
+				key, _ = decode(op.RequestPut.Key)
+			} else if op.RequestDeleteRange != nil {
+				key, _ = decode(op.RequestDeleteRange.Key)
+			}
+			if seen[key] {
+				http.Error(w, `{"error":"etcdserver: duplicate key given in txn request"}`, http.StatusBadRequest)
+				return
+			}
+			seen[key] = true
+		}
+		for _, op := range txn.Success {
+			if op.RequestPut != nil {
+				key, _ := decode(op.RequestPut.Key)
+				value, _ := decode(op.RequestPut.Value)
+				f.kvs[key] = value
+			} else if op.RequestDeleteRange != nil {
+				key, _ := decode(op.RequestDeleteRange.Key)
+				delete(f.kvs, key)
+			}
+		}
+		f.txns++
+		w.Write([]byte("{}"))
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix string
+		end    string
+	}{
+		{"/skydns/com/example/", "/skydns/com/example0"},
+		{"a", "b"},
+		{"a\xff", "b"},
+		{"a\xff\xff", "b"},
+		{"\xff", "\x00"},
+		{"", "\x00"},
+	}
+
+	for _, test := range tests {
+		if end := prefixEnd(test.prefix); end != test.end {
+			t.Errorf("%q: expected range end %q, got %q", test.prefix, test.end, end)
+		}
+	}
+}
+
+func TestGetPrefix(t *testing.T) {
+	_, c, stop := newFakeEtcd(map[string]string{
+		"/skydns/com/example/lb/kube-rdns-0":   "a",
+		"/skydns/com/example/lb/x/kube-rdns-0": "b",
+		"/skydns/com/example/lbx/kube-rdns-0":  "c",
+		"/skydns/com/example/lb":               "d",
+	})
+	defer stop()
+
+	kvs, err := c.getPrefix("/skydns/com/example/lb/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"/skydns/com/example/lb/kube-rdns-0":   "a",
+		"/skydns/com/example/lb/x/kube-rdns-0": "b",
+	}
+	if !reflect.DeepEqual(kvs, expected) {
+		t.Errorf("expected %v, got %v", expected, kvs)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	f, c, stop := newFakeEtcd(map[string]string{
+		"/skydns/a/kube-rdns-0": "old-0",
+		"/skydns/a/kube-rdns-1": "old-1",
+		"/skydns/b/kube-rdns-0": "other",
+	})
+	defer stop()
+
+	// kube-rdns-0 is deleted and put, the fake etcd refuses the txn unless the delete is skipped
+	err := c.replace(
+		[]string{"/skydns/a/kube-rdns-0", "/skydns/a/kube-rdns-1"},
+		map[string]string{"/skydns/a/kube-rdns-0": "new-0"},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"/skydns/a/kube-rdns-0": "new-0",
+		"/skydns/b/kube-rdns-0": "other",
+	}
+	if !reflect.DeepEqual(f.kvs, expected) {
+		t.Errorf("expected %v, got %v", expected, f.kvs)
+	}
+	if f.txns != 1 {
+		t.Errorf("expected a single transaction, got %d", f.txns)
+	}
+}
+
+func TestPostError(t *testing.T) {
+	_, c, stop := newFakeEtcd(nil)
+	defer stop()
+
+	if err := c.post("/v3/unknown", struct{}{}, nil); err == nil {
+		t.Error("expected an error for a non-2xx response")
+	}
+}
@@ -0,0 +1,186 @@
+package coredns
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/niusmallnan/kube-rdns/setting"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Name is the name of the coredns provider
+	Name = "coredns"
+
+	recordPrefix = "kube-rdns-"
+	recordTTL    = 60
+)
+
+// record is a SkyDNS record as read by the CoreDNS etcd plugin
+type record struct {
+	Host string `json:"host"`
+	TTL  uint32 `json:"ttl"`
+}
+
+// Provider keeps the records in etcd for the CoreDNS etcd plugin. CoreDNS has no wildcard
+// records, so every ingress hostname gets its own copy of the root domain records.
+type Provider struct {
+	etcd       *etcdClient
+	pathPrefix string
+	fqdn       string
+}
+
+func NewProvider() (*Provider, error) {
+	fqdn := strings.ToLower(strings.TrimSuffix(setting.GetCorednsDomain(), "."))
+	if fqdn == "" {
+		return nil, errors.New("coredns provider: domain should not be empty")
+	}
+
+	return &Provider{
+		etcd: &etcdClient{
+			httpClient: &http.Client{Timeout: 5 * time.Second},
+			endpoint:   strings.TrimSuffix(setting.GetEtcdEndpoint(), "/"),
+		},
+		pathPrefix: setting.GetCorednsPathPrefix(),
+		fqdn:       fqdn,
+	}, nil
+}
+
+func (p *Provider) GetName() string {
+	return Name
+}
+
+func (p *Provider) GetRootFqdn() string {
+	return p.fqdn
+}
+
+// dir returns the etcd directory of a domain name, lb.example.com becomes /skydns/com/example/lb
+func (p *Provider) dir(name string) string {
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(name, ".")), ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return path.Join(append([]string{"/", p.pathPrefix}, labels...)...)
+}
+
+// list returns the keys and hosts of the records under the root domain, grouped by directory
+func (p *Provider) list() (map[string][]string, map[string][]string, error) {
+	kvs, err := p.etcd.getPrefix(p.dir(p.fqdn) + "/")
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list records")
+	}
+
+	keys := map[string][]string{}
+	hosts := map[string][]string{}
+	for key, value := range kvs {
+		if !strings.HasPrefix(path.Base(key), recordPrefix) {
+			continue
+		}
+		var r record
+		if err := json.Unmarshal([]byte(value), &r); err != nil {
+			logrus.Warnf("Skip invalid record %s: %v", key, err)
+			continue
+		}
+		dir := path.Dir(key)
+		keys[dir] = append(keys[dir], key)
+		hosts[dir] = append(hosts[dir], r.Host)
+	}
+	for dir := range hosts {
+		sort.Strings(hosts[dir])
+	}
+
+	return keys, hosts, nil
+}
+
+// set replaces the records of dir with the hosts
+func (p *Provider) set(dir string, oldKeys, hosts []string) error {
+	puts := make(map[string]string, len(hosts))
+	for i, host := range hosts {
+		value, err := json.Marshal(&record{Host: host, TTL: recordTTL})
+		if err != nil {
+			return err
+		}
+		puts[path.Join(dir, fmt.Sprintf("%s%d", recordPrefix, i))] = string(value)
+	}
+
+	return p.etcd.replace(oldKeys, puts)
+}
+
+// MaxHosts returns 0, the etcd plugin has no limit on the records of a name
+func (p *Provider) MaxHosts() int {
+	return 0
+}
+
+func (p *Provider) Records() ([]string, error) {
+	_, hosts, err := p.list()
+	if err != nil {
+		return nil, errors.Wrap(err, "Records")
+	}
+	return hosts[p.dir(p.fqdn)], nil
+}
+
+func (p *Provider) ApplyChanges(hosts []string) error {
+	keys, current, err := p.list()
+	if err != nil {
+		return errors.Wrap(err, "ApplyChanges")
+	}
+
+	// the root domain and every hostname published under it point to the same hosts,
+	// the root domain goes last so that a failed update is retried by the next sync
+	root := p.dir(p.fqdn)
+	dirs := make([]string, 0, len(current)+1)
+	for dir := range current {
+		if dir != root {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	dirs = append(dirs, root)
+
+	for _, dir := range dirs {
+		if reflect.DeepEqual(current[dir], hosts) {
+			continue
+		}
+		logrus.Debugf("Records of %s have some changes, need to update", dir)
+		if err := p.set(dir, keys[dir], hosts); err != nil {
+			return errors.Wrapf(err, "ApplyChanges: failed to update records of %s", dir)
+		}
+	}
+
+	return nil
+}
+
+func (p *Provider) ApplyHostname(hostname string) error {
+	if !strings.HasSuffix(strings.ToLower(hostname), "."+p.fqdn) {
+		return errors.Errorf("ApplyHostname: %s is not under the root domain %s", hostname, p.fqdn)
+	}
+
+	keys, current, err := p.list()
+	if err != nil {
+		return errors.Wrap(err, "ApplyHostname")
+	}
+
+	hosts := current[p.dir(p.fqdn)]
+	if len(hosts) == 0 {
+		return errors.Errorf("ApplyHostname: root domain %s has no hosts yet", p.fqdn)
+	}
+
+	dir := p.dir(hostname)
+	if reflect.DeepEqual(current[dir], hosts) {
+		return nil
+	}
+
+	return errors.Wrapf(p.set(dir, keys[dir], hosts), "ApplyHostname: failed to update records of %s", hostname)
+}
+
+// Renew is a no-op, records in etcd do not expire
+func (p *Provider) Renew() error {
+	return nil
+}
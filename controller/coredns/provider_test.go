@@ -0,0 +1,149 @@
+package coredns
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func newTestProvider(kvs map[string]string) (*Provider, *fakeEtcd, func()) {
+	f, c, stop := newFakeEtcd(kvs)
+	return &Provider{etcd: c, pathPrefix: "/skydns", fqdn: "lb.example.com"}, f, stop
+}
+
+func recordValue(t *testing.T, host string) string {
+	value, err := json.Marshal(&record{Host: host, TTL: recordTTL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(value)
+}
+
+func TestDir(t *testing.T) {
+	p := &Provider{pathPrefix: "/skydns", fqdn: "lb.example.com"}
+
+	tests := []struct {
+		name string
+		dir  string
+	}{
+		{"lb.example.com", "/skydns/com/example/lb"},
+		{"lb.example.com.", "/skydns/com/example/lb"},
+		{"Foo.Default.LB.example.com", "/skydns/com/example/lb/default/foo"},
+	}
+
+	for _, test := range tests {
+		if dir := p.dir(test.name); dir != test.dir {
+			t.Errorf("%s: expected dir %s, got %s", test.name, test.dir, dir)
+		}
+	}
+
+	p.pathPrefix = "skydns/"
+	if dir := p.dir("lb.example.com"); dir != "/skydns/com/example/lb" {
+		t.Errorf("expected the path prefix to be cleaned, got %s", dir)
+	}
+}
+
+func TestRecords(t *testing.T) {
+	p, _, stop := newTestProvider(map[string]string{
+		"/skydns/com/example/lb/kube-rdns-0":             recordValue(t, "203.0.113.9"),
+		"/skydns/com/example/lb/kube-rdns-1":             recordValue(t, "203.0.113.5"),
+		"/skydns/com/example/lb/other":                   recordValue(t, "192.0.2.1"),
+		"/skydns/com/example/lb/default/foo/kube-rdns-0": recordValue(t, "198.51.100.1"),
+	})
+	defer stop()
+
+	hosts, err := p.Records()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"203.0.113.5", "203.0.113.9"}; !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("expected %v, got %v", expected, hosts)
+	}
+}
+
+func TestApplyChanges(t *testing.T) {
+	p, f, stop := newTestProvider(map[string]string{
+		"/skydns/com/example/lb/kube-rdns-0":             recordValue(t, "203.0.113.1"),
+		"/skydns/com/example/lb/kube-rdns-1":             recordValue(t, "203.0.113.2"),
+		"/skydns/com/example/lb/default/foo/kube-rdns-0": recordValue(t, "203.0.113.1"),
+		"/skydns/com/example/lb/default/foo/kube-rdns-1": recordValue(t, "203.0.113.2"),
+		"/skydns/com/example/lb/default/bar/kube-rdns-0": recordValue(t, "203.0.113.1"),
+		"/skydns/com/example/other/kube-rdns-0":          recordValue(t, "192.0.2.1"),
+	})
+	defer stop()
+
+	if err := p.ApplyChanges([]string{"203.0.113.5"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the root domain and every hostname dir are rewritten, names outside the root domain are kept
+	expected := map[string]string{
+		"/skydns/com/example/lb/kube-rdns-0":             recordValue(t, "203.0.113.5"),
+		"/skydns/com/example/lb/default/foo/kube-rdns-0": recordValue(t, "203.0.113.5"),
+		"/skydns/com/example/lb/default/bar/kube-rdns-0": recordValue(t, "203.0.113.5"),
+		"/skydns/com/example/other/kube-rdns-0":          recordValue(t, "192.0.2.1"),
+	}
+	if !reflect.DeepEqual(f.kvs, expected) {
+		t.Errorf("expected %v, got %v", expected, f.kvs)
+	}
+	if f.txns != 3 {
+		t.Errorf("expected one transaction per dir, got %d", f.txns)
+	}
+}
+
+func TestApplyChangesCreatesRoot(t *testing.T) {
+	p, f, stop := newTestProvider(nil)
+	defer stop()
+
+	if err := p.ApplyChanges([]string{"203.0.113.5", "203.0.113.9"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"/skydns/com/example/lb/kube-rdns-0": recordValue(t, "203.0.113.5"),
+		"/skydns/com/example/lb/kube-rdns-1": recordValue(t, "203.0.113.9"),
+	}
+	if !reflect.DeepEqual(f.kvs, expected) {
+		t.Errorf("expected %v, got %v", expected, f.kvs)
+	}
+}
+
+func TestApplyHostname(t *testing.T) {
+	p, f, stop := newTestProvider(map[string]string{
+		"/skydns/com/example/lb/kube-rdns-0": recordValue(t, "203.0.113.5"),
+	})
+	defer stop()
+
+	for _, hostname := range []string{"foo.default.example.com", "lb.example.com", "foo.default.xlb.example.com"} {
+		if err := p.ApplyHostname(hostname); err == nil {
+			t.Errorf("%s: expected a hostname outside the root domain to be rejected", hostname)
+		}
+	}
+	if f.txns != 0 {
+		t.Fatalf("expected no writes for rejected hostnames, got %d", f.txns)
+	}
+
+	if err := p.ApplyHostname("foo.default.lb.example.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value := f.kvs["/skydns/com/example/lb/default/foo/kube-rdns-0"]; value != recordValue(t, "203.0.113.5") {
+		t.Errorf("expected the hostname to copy the root domain records, got %q", value)
+	}
+
+	// up to date hostnames are not written again
+	if err := p.ApplyHostname("foo.default.lb.example.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.txns != 1 {
+		t.Errorf("expected a single write, got %d", f.txns)
+	}
+}
+
+func TestApplyHostnameWithoutRoot(t *testing.T) {
+	p, _, stop := newTestProvider(nil)
+	defer stop()
+
+	if err := p.ApplyHostname("foo.default.lb.example.com"); err == nil {
+		t.Error("expected an error when the root domain has no hosts yet")
+	}
+}
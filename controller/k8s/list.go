@@ -0,0 +1,70 @@
+package k8s
+
+import (
+	"github.com/niusmallnan/kube-rdns/setting"
+	k8scorev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// chunked returns the options of a chunked list. Chunking is off unless --list-chunk-size is set,
+// apiservers without the APIListChunking feature ignore limit and return everything in one page.
+func chunked(options metav1.ListOptions) metav1.ListOptions {
+	size := setting.GetListChunkSize()
+	if size <= 0 {
+		return options
+	}
+
+	// a list from the watch cache (resourceVersion=0) ignores limit,
+	// so chunking turns the cheap list of an informer into a consistent read from etcd
+	options.ResourceVersion = ""
+	options.Limit = int64(size)
+	return options
+}
+
+// ListPods pages through the pods with limit/continue and returns them all
+func ListPods(client kubernetes.Interface, namespace string, options metav1.ListOptions) (*k8scorev1.PodList, error) {
+	options = chunked(options)
+	list := &k8scorev1.PodList{}
+	for {
+		page, err := client.CoreV1().Pods(namespace).List(options)
+		if err != nil {
+			return nil, err
+		}
+		if list.ResourceVersion == "" {
+			list.ListMeta = page.ListMeta
+		}
+		list.Items = append(list.Items, page.Items...)
+		if page.Continue == "" {
+			break
+		}
+		options.Continue = page.Continue
+	}
+	list.Continue = ""
+
+	return list, nil
+}
+
+// ListIngresses pages through the ingresses with limit/continue and returns them all
+func ListIngresses(client kubernetes.Interface, namespace string, options metav1.ListOptions) (*extensionsv1beta1.IngressList, error) {
+	options = chunked(options)
+	list := &extensionsv1beta1.IngressList{}
+	for {
+		page, err := client.ExtensionsV1beta1().Ingresses(namespace).List(options)
+		if err != nil {
+			return nil, err
+		}
+		if list.ResourceVersion == "" {
+			list.ListMeta = page.ListMeta
+		}
+		list.Items = append(list.Items, page.Items...)
+		if page.Continue == "" {
+			break
+		}
+		options.Continue = page.Continue
+	}
+	list.Continue = ""
+
+	return list, nil
+}
@@ -0,0 +1,230 @@
+package k8s
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/niusmallnan/kube-rdns/setting"
+	"github.com/urfave/cli"
+	k8scorev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	typedextensionsv1beta1 "k8s.io/client-go/kubernetes/typed/extensions/v1beta1"
+)
+
+// pager serves count objects in pages of options.Limit, the continue token is the offset of
+// the next page. The typed fakes of this client-go rebuild the list without its ListMeta, so a
+// reactor can not hand out continue tokens and the typed clients are replaced instead.
+type pager struct {
+	count int
+	calls []metav1.ListOptions
+}
+
+func (p *pager) page(options metav1.ListOptions) (int, int, metav1.ListMeta, error) {
+	p.calls = append(p.calls, options)
+	start := 0
+	if options.Continue != "" {
+		var err error
+		if start, err = strconv.Atoi(options.Continue); err != nil {
+			return 0, 0, metav1.ListMeta{}, err
+		}
+	}
+
+	meta := metav1.ListMeta{ResourceVersion: strconv.Itoa(100 + len(p.calls))}
+	end := start + int(options.Limit)
+	if options.Limit == 0 || end >= p.count {
+		end = p.count
+	} else {
+		meta.Continue = strconv.Itoa(end)
+	}
+	return start, end, meta, nil
+}
+
+type pagedPods struct {
+	corev1.PodInterface
+	*pager
+}
+
+func (p *pagedPods) List(options metav1.ListOptions) (*k8scorev1.PodList, error) {
+	start, end, meta, err := p.page(options)
+	if err != nil {
+		return nil, err
+	}
+	list := &k8scorev1.PodList{ListMeta: meta}
+	for i := start; i < end; i++ {
+		list.Items = append(list.Items, k8scorev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pod-%d", i)}})
+	}
+	return list, nil
+}
+
+type pagedIngresses struct {
+	typedextensionsv1beta1.IngressInterface
+	*pager
+}
+
+func (p *pagedIngresses) List(options metav1.ListOptions) (*extensionsv1beta1.IngressList, error) {
+	start, end, meta, err := p.page(options)
+	if err != nil {
+		return nil, err
+	}
+	list := &extensionsv1beta1.IngressList{ListMeta: meta}
+	for i := start; i < end; i++ {
+		list.Items = append(list.Items, extensionsv1beta1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("ingress-%d", i)}})
+	}
+	return list, nil
+}
+
+type pagedCoreV1 struct {
+	corev1.CoreV1Interface
+	pods *pagedPods
+}
+
+func (c *pagedCoreV1) Pods(namespace string) corev1.PodInterface {
+	return c.pods
+}
+
+type pagedExtensionsV1beta1 struct {
+	typedextensionsv1beta1.ExtensionsV1beta1Interface
+	ingresses *pagedIngresses
+}
+
+func (c *pagedExtensionsV1beta1) Ingresses(namespace string) typedextensionsv1beta1.IngressInterface {
+	return c.ingresses
+}
+
+type pagedClient struct {
+	kubernetes.Interface
+	core       *pagedCoreV1
+	extensions *pagedExtensionsV1beta1
+}
+
+func (c *pagedClient) CoreV1() corev1.CoreV1Interface {
+	return c.core
+}
+
+func (c *pagedClient) ExtensionsV1beta1() typedextensionsv1beta1.ExtensionsV1beta1Interface {
+	return c.extensions
+}
+
+func newPagedClient(p *pager) *pagedClient {
+	client := fake.NewSimpleClientset()
+	return &pagedClient{
+		Interface:  client,
+		core:       &pagedCoreV1{CoreV1Interface: client.CoreV1(), pods: &pagedPods{pager: p}},
+		extensions: &pagedExtensionsV1beta1{ExtensionsV1beta1Interface: client.ExtensionsV1beta1(), ingresses: &pagedIngresses{pager: p}},
+	}
+}
+
+const testChunkSize = 500
+
+func initChunkSize(size string) {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String("list-chunk-size", size, "")
+	setting.Init(cli.NewContext(nil, set, nil))
+}
+
+func checkCalls(t *testing.T, p *pager, pages int) {
+	if len(p.calls) != pages {
+		t.Fatalf("expected %d list calls, got %d", pages, len(p.calls))
+	}
+	for i, options := range p.calls {
+		if options.Limit != testChunkSize {
+			t.Errorf("call %d: expected limit %d, got %d", i, testChunkSize, options.Limit)
+		}
+		if options.ResourceVersion != "" {
+			t.Errorf("call %d: expected a consistent read, got resourceVersion %q", i, options.ResourceVersion)
+		}
+		if options.LabelSelector != "app=ingress-nginx" {
+			t.Errorf("call %d: expected the label selector to be kept, got %q", i, options.LabelSelector)
+		}
+		continueToken := ""
+		if i > 0 {
+			continueToken = strconv.Itoa(i * testChunkSize)
+		}
+		if options.Continue != continueToken {
+			t.Errorf("call %d: expected continue %q, got %q", i, continueToken, options.Continue)
+		}
+	}
+}
+
+func TestListPods(t *testing.T) {
+	initChunkSize(strconv.Itoa(testChunkSize))
+
+	tests := []struct {
+		count int
+		pages int
+	}{
+		{0, 1},
+		{testChunkSize, 1},
+		{2*testChunkSize + 1, 3},
+	}
+
+	for _, test := range tests {
+		p := &pager{count: test.count}
+		options := metav1.ListOptions{LabelSelector: "app=ingress-nginx", ResourceVersion: "0"}
+		list, err := ListPods(newPagedClient(p), "ingress-nginx", options)
+		if err != nil {
+			t.Fatalf("%d pods: unexpected error: %v", test.count, err)
+		}
+
+		checkCalls(t, p, test.pages)
+		if len(list.Items) != test.count {
+			t.Fatalf("%d pods: got %d", test.count, len(list.Items))
+		}
+		for i, pod := range list.Items {
+			if name := fmt.Sprintf("pod-%d", i); pod.Name != name {
+				t.Fatalf("%d pods: expected %s at %d, got %s", test.count, name, i, pod.Name)
+			}
+		}
+		if list.ResourceVersion != "101" || list.Continue != "" {
+			t.Errorf("%d pods: expected the list meta of the first page, got %+v", test.count, list.ListMeta)
+		}
+	}
+}
+
+func TestListIngresses(t *testing.T) {
+	initChunkSize(strconv.Itoa(testChunkSize))
+
+	p := &pager{count: 3*testChunkSize - 1}
+	options := metav1.ListOptions{LabelSelector: "app=ingress-nginx"}
+	list, err := ListIngresses(newPagedClient(p), k8scorev1.NamespaceAll, options)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checkCalls(t, p, 3)
+	if len(list.Items) != p.count {
+		t.Fatalf("expected %d ingresses, got %d", p.count, len(list.Items))
+	}
+	if last := list.Items[len(list.Items)-1].Name; last != fmt.Sprintf("ingress-%d", p.count-1) {
+		t.Errorf("unexpected last ingress %s", last)
+	}
+	if list.ResourceVersion != "101" || list.Continue != "" {
+		t.Errorf("expected the list meta of the first page, got %+v", list.ListMeta)
+	}
+}
+
+func TestListWithoutChunking(t *testing.T) {
+	initChunkSize("0")
+
+	// the options go out as they are, an informer keeps its list from the watch cache
+	p := &pager{count: 3*testChunkSize - 1}
+	options := metav1.ListOptions{LabelSelector: "app=ingress-nginx", ResourceVersion: "0"}
+	list, err := ListIngresses(newPagedClient(p), k8scorev1.NamespaceAll, options)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := []metav1.ListOptions{options}; !reflect.DeepEqual(p.calls, expected) {
+		t.Errorf("expected a single list with %+v, got %+v", expected, p.calls)
+	}
+	if len(list.Items) != p.count {
+		t.Errorf("expected %d ingresses, got %d", p.count, len(list.Items))
+	}
+}
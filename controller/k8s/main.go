@@ -1,17 +1,21 @@
 package k8s
 
 import (
+	"github.com/niusmallnan/kube-rdns/controller/utils"
+	"github.com/niusmallnan/kube-rdns/setting"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	k8scorev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 )
 
 const (
 	secretKey = "rdns-token"
 )
 
-func GetTokenAndRootFqdn(client *kubernetes.Clientset) (string, string) {
+func GetTokenAndRootFqdn(client kubernetes.Interface) (string, string) {
 	secret, err := client.CoreV1().Secrets(metav1.NamespaceSystem).Get(secretKey, metav1.GetOptions{})
 	if err != nil {
 		logrus.Warnf("Warning: failed to get token and fqdn from secret, err: %v", err)
@@ -21,7 +25,7 @@ func GetTokenAndRootFqdn(client *kubernetes.Clientset) (string, string) {
 	return string(secret.Data["token"]), string(secret.Data["fqdn"])
 }
 
-func SaveTokenAndRootFqdn(client *kubernetes.Clientset, token, fqdn string) error {
+func SaveTokenAndRootFqdn(client kubernetes.Interface, token, fqdn string) error {
 	_, err := client.CoreV1().Secrets(metav1.NamespaceSystem).Create(&k8scorev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      secretKey,
@@ -36,8 +40,36 @@ func SaveTokenAndRootFqdn(client *kubernetes.Clientset, token, fqdn string) erro
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"token": token,
-			"fqdn":  fqdn}).Fatal("Failed to save token and fqdn to secret, err: %v", err)
+			"fqdn":  fqdn}).Fatalf("Failed to save token and fqdn to secret, err: %v", err)
 	}
 
 	return err
 }
+
+// GetServiceIPs returns the load balancer ips of the service, key is in namespace/name format
+func GetServiceIPs(client kubernetes.Interface, key string) ([]string, error) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if namespace == "" {
+		return nil, errors.Errorf("service %q should be in namespace/name format", key)
+	}
+
+	svc, err := client.CoreV1().Services(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []string
+	for _, i := range svc.Status.LoadBalancer.Ingress {
+		ip, err := utils.HostIP(i.IP, setting.IsGlobalIPsOnly())
+		if err != nil {
+			logrus.Debugf("Service /%s/%s: skip %v", namespace, name, err)
+			continue
+		}
+		ips = append(ips, ip)
+	}
+
+	return ips, nil
+}
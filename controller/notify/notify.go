@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionNone   = "none"
+	ActionApply  = "apply"
+
+	notifyTimeout = 5 * time.Second
+)
+
+// Summary is the result of applying hosts to the root domain
+type Summary struct {
+	Action string    `json:"action"`
+	Fqdn   string    `json:"fqdn,omitempty"`
+	Hosts  []string  `json:"hosts"`
+	Error  string    `json:"error,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+func (s *Summary) text() string {
+	domain := "domain"
+	if s.Fqdn != "" {
+		domain = "domain " + s.Fqdn
+	}
+	if s.Error != "" {
+		return fmt.Sprintf("kube-rdns failed to %s %s with hosts [%s]: %s", s.Action, domain, strings.Join(s.Hosts, ", "), s.Error)
+	}
+	return fmt.Sprintf("kube-rdns %s %s with hosts [%s]", s.Action, domain, strings.Join(s.Hosts, ", "))
+}
+
+// Notifier posts summaries to a webhook, it is best-effort and never blocks the caller
+type Notifier struct {
+	httpClient *http.Client
+	url        string
+	slack      bool
+	noop       bool
+}
+
+// NewNotifier returns nil if url is empty, a nil notifier sends nothing
+func NewNotifier(url string, slack, noop bool) *Notifier {
+	if url == "" {
+		return nil
+	}
+	return &Notifier{
+		httpClient: &http.Client{Timeout: notifyTimeout},
+		url:        url,
+		slack:      slack,
+		noop:       noop,
+	}
+}
+
+// Notify sends the summary in the background, no-op summaries are skipped unless enabled
+func (n *Notifier) Notify(action, fqdn string, hosts []string, err error) {
+	if n == nil {
+		return
+	}
+	if action == ActionNone && err == nil && !n.noop {
+		return
+	}
+
+	s := &Summary{
+		Action: action,
+		Fqdn:   fqdn,
+		Hosts:  hosts,
+		Time:   time.Now(),
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+
+	go n.send(s)
+}
+
+func (n *Notifier) send(s *Summary) {
+	var payload interface{} = s
+	if n.slack {
+		payload = map[string]string{"text": s.text()}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(payload); err != nil {
+		logrus.Errorf("Failed to encode notification: %v", err)
+		return
+	}
+
+	resp, err := n.httpClient.Post(n.url, "application/json", buf)
+	if err != nil {
+		logrus.Warnf("Failed to send notification: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if code := resp.StatusCode; code < 200 || code >= 300 {
+		logrus.Warnf("Failed to send notification, got status code %d", code)
+	}
+}
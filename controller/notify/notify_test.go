@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func newWebhook() (*httptest.Server, chan []byte) {
+	received := make(chan []byte, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		received <- body
+	}))
+	return srv, received
+}
+
+func receive(t *testing.T, received chan []byte) []byte {
+	select {
+	case body := <-received:
+		return body
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a notification")
+	}
+	return nil
+}
+
+func TestNotifyJSON(t *testing.T) {
+	srv, received := newWebhook()
+	defer srv.Close()
+	n := NewNotifier(srv.URL, false, false)
+
+	n.Notify(ActionUpdate, "abcdef.lb.rancher.cloud", []string{"203.0.113.5"}, nil)
+	var s Summary
+	if err := json.Unmarshal(receive(t, received), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Action != ActionUpdate || s.Fqdn != "abcdef.lb.rancher.cloud" || !reflect.DeepEqual(s.Hosts, []string{"203.0.113.5"}) || s.Error != "" {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	if s.Time.IsZero() {
+		t.Error("expected the summary to carry a time")
+	}
+
+	n.Notify(ActionCreate, "", []string{"203.0.113.5"}, errors.New("rdns server error"))
+	s = Summary{}
+	if err := json.Unmarshal(receive(t, received), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Action != ActionCreate || s.Error != "rdns server error" {
+		t.Errorf("unexpected summary %+v", s)
+	}
+}
+
+func TestNotifySlack(t *testing.T) {
+	srv, received := newWebhook()
+	defer srv.Close()
+	n := NewNotifier(srv.URL, true, false)
+
+	tests := []struct {
+		action string
+		fqdn   string
+		err    error
+		text   string
+	}{
+		{ActionUpdate, "abcdef.lb.rancher.cloud", nil, "kube-rdns update domain abcdef.lb.rancher.cloud with hosts [203.0.113.5, 203.0.113.9]"},
+		{ActionCreate, "", errors.New("rdns server error"), "kube-rdns failed to create domain with hosts [203.0.113.5, 203.0.113.9]: rdns server error"},
+	}
+
+	for _, test := range tests {
+		n.Notify(test.action, test.fqdn, []string{"203.0.113.5", "203.0.113.9"}, test.err)
+		var payload map[string]string
+		if err := json.Unmarshal(receive(t, received), &payload); err != nil {
+			t.Fatal(err)
+		}
+		if expected := map[string]string{"text": test.text}; !reflect.DeepEqual(payload, expected) {
+			t.Errorf("expected payload %v, got %v", expected, payload)
+		}
+	}
+}
+
+func TestNotifyNoop(t *testing.T) {
+	srv, received := newWebhook()
+	defer srv.Close()
+
+	// a skipped no-op sends nothing, so the next notification is the first one received
+	n := NewNotifier(srv.URL, false, false)
+	n.Notify(ActionNone, "abcdef.lb.rancher.cloud", []string{"203.0.113.5"}, nil)
+	n.Notify(ActionNone, "abcdef.lb.rancher.cloud", []string{"203.0.113.5"}, errors.New("failed"))
+	var s Summary
+	if err := json.Unmarshal(receive(t, received), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Error != "failed" {
+		t.Errorf("expected the no-op without error to be skipped, got %+v", s)
+	}
+
+	n = NewNotifier(srv.URL, false, true)
+	n.Notify(ActionNone, "abcdef.lb.rancher.cloud", []string{"203.0.113.5"}, nil)
+	s = Summary{}
+	if err := json.Unmarshal(receive(t, received), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Action != ActionNone || s.Error != "" {
+		t.Errorf("expected the no-op to be sent, got %+v", s)
+	}
+}
+
+func TestNilNotifier(t *testing.T) {
+	n := NewNotifier("", false, false)
+	if n != nil {
+		t.Fatal("expected no notifier without an url")
+	}
+	// a nil notifier is safe to use
+	n.Notify(ActionUpdate, "abcdef.lb.rancher.cloud", []string{"203.0.113.5"}, nil)
+}
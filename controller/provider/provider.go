@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"github.com/niusmallnan/kube-rdns/controller/coredns"
+	"github.com/niusmallnan/kube-rdns/controller/rdns"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Provider publishes the cluster root domain and the ingress hostnames under it to a dns backend
+type Provider interface {
+	// GetName returns the name the provider is selected by
+	GetName() string
+	// GetRootFqdn returns the root domain, empty if it has not been created yet
+	GetRootFqdn() string
+	// MaxHosts returns the most hosts the root domain can point to, 0 if there is no limit
+	MaxHosts() int
+	// Records returns the hosts the root domain currently points to, empty if it has not been created yet
+	Records() ([]string, error)
+	// ApplyChanges points the root domain and all hostnames under it to the hosts,
+	// it is only called by the Syncer with sorted hosts which differ from the records
+	ApplyChanges(hosts []string) error
+	// ApplyHostname publishes a hostname under the root domain with the same hosts as the root domain
+	ApplyHostname(hostname string) error
+	// Renew keeps the root domain from expiring
+	Renew() error
+}
+
+// IsSkipped returns true if err means the provider did not try the call at all,
+// e.g. because its circuit breaker is open. Such errors are not worth reporting.
+func IsSkipped(err error) bool {
+	skipped, ok := errors.Cause(err).(interface {
+		Skipped() bool
+	})
+	return ok && skipped.Skipped()
+}
+
+// ByName returns the provider registered with the name
+func ByName(name string, kubeClient kubernetes.Interface) (Provider, error) {
+	switch name {
+	case rdns.Name:
+		return rdns.NewClient(kubeClient), nil
+	case coredns.Name:
+		return coredns.NewProvider()
+	}
+
+	return nil, errors.Errorf("unknown provider %q", name)
+}
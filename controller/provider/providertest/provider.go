@@ -0,0 +1,84 @@
+// Package providertest has an in-memory dns provider for tests of the packages which apply hosts
+package providertest
+
+// CreatedFqdn is the root domain ApplyChanges creates when Fqdn is empty
+const CreatedFqdn = "abcdef.lb.rancher.cloud"
+
+// ErrSkipped is an error which provider.IsSkipped reports as skipped, like an open circuit breaker
+var ErrSkipped error = skippedError{}
+
+type skippedError struct{}
+
+func (skippedError) Error() string {
+	return "circuit breaker is open"
+}
+
+func (skippedError) Skipped() bool {
+	return true
+}
+
+// Provider implements provider.Provider in memory. Hosts are its records, ApplyChanges replaces
+// them and records every call in Applied, ApplyHostname records the hostnames in Hostnames.
+type Provider struct {
+	Fqdn  string
+	Hosts []string
+	// Limit is returned by MaxHosts
+	Limit int
+
+	RecordsErr  error
+	ApplyErr    error
+	HostnameErr error
+
+	// when set, ApplyChanges signals Entered and waits for Release
+	Entered chan struct{}
+	Release chan struct{}
+
+	Applied   [][]string
+	Hostnames []string
+}
+
+func (p *Provider) GetName() string {
+	return "fake"
+}
+
+func (p *Provider) GetRootFqdn() string {
+	return p.Fqdn
+}
+
+func (p *Provider) MaxHosts() int {
+	return p.Limit
+}
+
+func (p *Provider) Records() ([]string, error) {
+	return p.Hosts, p.RecordsErr
+}
+
+func (p *Provider) ApplyChanges(hosts []string) error {
+	if p.Entered != nil {
+		p.Entered <- struct{}{}
+		<-p.Release
+	}
+	if p.ApplyErr != nil {
+		return p.ApplyErr
+	}
+
+	p.Applied = append(p.Applied, hosts)
+	p.Hosts = hosts
+	if p.Fqdn == "" {
+		p.Fqdn = CreatedFqdn
+	}
+	return nil
+}
+
+func (p *Provider) ApplyHostname(hostname string) error {
+	if p.HostnameErr != nil {
+		return p.HostnameErr
+	}
+
+	p.Hostnames = append(p.Hostnames, hostname)
+	return nil
+}
+
+func (p *Provider) Renew() error {
+	return nil
+}
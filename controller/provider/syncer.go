@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/niusmallnan/kube-rdns/controller/notify"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrApplyRunning is returned by TryApply when another apply has not finished yet
+var ErrApplyRunning = errors.New("apply is already running")
+
+// Result is the outcome of applying hosts to the root domain
+type Result struct {
+	Action string   `json:"action"`
+	Fqdn   string   `json:"fqdn"`
+	Hosts  []string `json:"hosts"`
+}
+
+// Syncer applies the desired hosts through a provider. It compares them with the current
+// records, so that the provider is only called when something changed, and notifies the outcome.
+type Syncer struct {
+	provider Provider
+	notifier *notify.Notifier
+	// guard is held for the whole apply, two concurrent creates would both register a domain
+	guard chan struct{}
+}
+
+func NewSyncer(p Provider, notifier *notify.Notifier) *Syncer {
+	return &Syncer{
+		provider: p,
+		notifier: notifier,
+		guard:    make(chan struct{}, 1),
+	}
+}
+
+func (s *Syncer) GetRootFqdn() string {
+	return s.provider.GetRootFqdn()
+}
+
+// Apply points the root domain to the hosts, then publishes the hostnames under it.
+// It waits for a running apply to finish first.
+func (s *Syncer) Apply(hosts []string, hostnames ...string) (*Result, error) {
+	s.guard <- struct{}{}
+	defer func() { <-s.guard }()
+
+	return s.apply(hosts, hostnames)
+}
+
+// TryApply is like Apply, but it returns ErrApplyRunning instead of waiting
+func (s *Syncer) TryApply(hosts []string, hostnames ...string) (*Result, error) {
+	select {
+	case s.guard <- struct{}{}:
+	default:
+		return nil, ErrApplyRunning
+	}
+	defer func() { <-s.guard }()
+
+	return s.apply(hosts, hostnames)
+}
+
+func (s *Syncer) apply(hosts, hostnames []string) (*Result, error) {
+	result, err := s.applyChanges(hosts)
+	if err == nil {
+		// the root domain is already applied, so the result keeps its action
+		for _, hostname := range hostnames {
+			if err = s.provider.ApplyHostname(hostname); err != nil {
+				err = errors.Wrapf(err, "failed to publish hostname %s", hostname)
+				break
+			}
+		}
+	}
+
+	// a skipped call changed nothing and is tried again by the next sync
+	if !IsSkipped(err) {
+		s.notifier.Notify(result.Action, result.Fqdn, result.Hosts, err)
+	}
+	return result, err
+}
+
+func (s *Syncer) applyChanges(hosts []string) (*Result, error) {
+	// never reorder the slice of the caller
+	hosts = append([]string(nil), hosts...)
+	sort.Strings(hosts)
+	if max := s.provider.MaxHosts(); max > 0 && len(hosts) > max {
+		logrus.Debugf("hosts number is %d, over %d", len(hosts), max)
+		hosts = hosts[:max]
+	}
+
+	result := &Result{Action: notify.ActionApply, Fqdn: s.provider.GetRootFqdn(), Hosts: hosts}
+	if len(hosts) == 0 {
+		return result, errors.New("ApplyChanges: hosts should not be empty")
+	}
+
+	current, err := s.provider.Records()
+	if err != nil {
+		return result, errors.Wrap(err, "ApplyChanges: failed to get current records")
+	}
+	current = append([]string(nil), current...)
+	sort.Strings(current)
+
+	switch {
+	case len(current) == 0:
+		logrus.Debugf("Fqdn for %s has not been exist, need to create a new one", hosts)
+		result.Action = notify.ActionCreate
+	case !reflect.DeepEqual(current, hosts):
+		logrus.Debugf("Fqdn %s has some changes, need to update", result.Fqdn)
+		result.Action = notify.ActionUpdate
+	default:
+		logrus.Debugf("Fqdn %s has no changes, no need to update", result.Fqdn)
+		result.Action = notify.ActionNone
+		return result, nil
+	}
+
+	if err := s.provider.ApplyChanges(hosts); err != nil {
+		return result, err
+	}
+	// the root domain of a new rdns domain is only known once it is created
+	result.Fqdn = s.provider.GetRootFqdn()
+
+	return result, nil
+}
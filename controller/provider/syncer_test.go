@@ -0,0 +1,248 @@
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/niusmallnan/kube-rdns/controller/notify"
+	"github.com/niusmallnan/kube-rdns/controller/provider/providertest"
+	"github.com/pkg/errors"
+)
+
+func TestSyncerApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider *providertest.Provider
+		hosts    []string
+		action   string
+		fqdn     string
+		result   []string
+		applied  [][]string
+	}{
+		{
+			name:     "create",
+			provider: &providertest.Provider{},
+			hosts:    []string{"203.0.113.9", "203.0.113.5"},
+			action:   notify.ActionCreate,
+			fqdn:     "abcdef.lb.rancher.cloud",
+			result:   []string{"203.0.113.5", "203.0.113.9"},
+			applied:  [][]string{{"203.0.113.5", "203.0.113.9"}},
+		},
+		{
+			name:     "update",
+			provider: &providertest.Provider{Fqdn: "abcdef.lb.rancher.cloud", Hosts: []string{"203.0.113.1"}},
+			hosts:    []string{"203.0.113.9", "203.0.113.5"},
+			action:   notify.ActionUpdate,
+			fqdn:     "abcdef.lb.rancher.cloud",
+			result:   []string{"203.0.113.5", "203.0.113.9"},
+			applied:  [][]string{{"203.0.113.5", "203.0.113.9"}},
+		},
+		{
+			name:     "none",
+			provider: &providertest.Provider{Fqdn: "abcdef.lb.rancher.cloud", Hosts: []string{"203.0.113.9", "203.0.113.5"}},
+			hosts:    []string{"203.0.113.5", "203.0.113.9"},
+			action:   notify.ActionNone,
+			fqdn:     "abcdef.lb.rancher.cloud",
+			result:   []string{"203.0.113.5", "203.0.113.9"},
+		},
+		{
+			name:     "truncate",
+			provider: &providertest.Provider{Fqdn: "abcdef.lb.rancher.cloud", Limit: 2, Hosts: []string{"203.0.113.1", "203.0.113.2"}},
+			hosts:    []string{"203.0.113.3", "203.0.113.2", "203.0.113.1"},
+			action:   notify.ActionNone,
+			fqdn:     "abcdef.lb.rancher.cloud",
+			result:   []string{"203.0.113.1", "203.0.113.2"},
+		},
+	}
+
+	for _, test := range tests {
+		hosts := append([]string(nil), test.hosts...)
+		result, err := NewSyncer(test.provider, nil).Apply(hosts, "foo.default."+test.fqdn)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(hosts, test.hosts) {
+			t.Errorf("%s: expected the hosts of the caller to be kept as %v, got %v", test.name, test.hosts, hosts)
+		}
+		if result.Action != test.action || result.Fqdn != test.fqdn {
+			t.Errorf("%s: expected %s of %s, got %s of %s", test.name, test.action, test.fqdn, result.Action, result.Fqdn)
+		}
+		if !reflect.DeepEqual(test.provider.Applied, test.applied) {
+			t.Errorf("%s: expected %v to be applied, got %v", test.name, test.applied, test.provider.Applied)
+		}
+		if !reflect.DeepEqual(result.Hosts, test.result) {
+			t.Errorf("%s: expected result hosts %v, got %v", test.name, test.result, result.Hosts)
+		}
+		if expected := []string{"foo.default." + test.fqdn}; !reflect.DeepEqual(test.provider.Hostnames, expected) {
+			t.Errorf("%s: expected hostnames %v, got %v", test.name, expected, test.provider.Hostnames)
+		}
+	}
+}
+
+func TestSyncerApplyErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider *providertest.Provider
+		hosts    []string
+	}{
+		{
+			name:     "empty hosts",
+			provider: &providertest.Provider{Fqdn: "abcdef.lb.rancher.cloud"},
+		},
+		{
+			name:     "records error",
+			provider: &providertest.Provider{Fqdn: "abcdef.lb.rancher.cloud", RecordsErr: errors.New("boom")},
+			hosts:    []string{"203.0.113.5"},
+		},
+		{
+			name:     "apply error",
+			provider: &providertest.Provider{Fqdn: "abcdef.lb.rancher.cloud", ApplyErr: errors.New("boom")},
+			hosts:    []string{"203.0.113.5"},
+		},
+	}
+
+	for _, test := range tests {
+		if _, err := NewSyncer(test.provider, nil).Apply(test.hosts, "foo.default.abcdef.lb.rancher.cloud"); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+		if len(test.provider.Applied) != 0 || len(test.provider.Hostnames) != 0 {
+			t.Errorf("%s: expected nothing to be applied, got %v and %v", test.name, test.provider.Applied, test.provider.Hostnames)
+		}
+	}
+}
+
+func TestSyncerApplyHostnameError(t *testing.T) {
+	p := &providertest.Provider{HostnameErr: errors.New("not under the root domain")}
+	result, err := NewSyncer(p, nil).Apply([]string{"203.0.113.5"}, "foo.example.com")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// the root domain was created before the hostname failed, the result must say so
+	expected := &Result{Action: notify.ActionCreate, Fqdn: providertest.CreatedFqdn, Hosts: []string{"203.0.113.5"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+	if expected := [][]string{{"203.0.113.5"}}; !reflect.DeepEqual(p.Applied, expected) {
+		t.Errorf("expected %v to be applied, got %v", expected, p.Applied)
+	}
+}
+
+func TestSyncerNotify(t *testing.T) {
+	received := make(chan notify.Summary, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var s notify.Summary
+		json.NewDecoder(req.Body).Decode(&s)
+		received <- s
+	}))
+	defer srv.Close()
+	notifier := notify.NewNotifier(srv.URL, false, false)
+
+	tests := []struct {
+		name     string
+		provider *providertest.Provider
+		hosts    []string
+		notified bool
+		action   string
+		err      string
+	}{
+		{
+			name:     "empty hosts",
+			provider: &providertest.Provider{Fqdn: "abcdef.lb.rancher.cloud"},
+			notified: true,
+			action:   notify.ActionApply,
+			err:      "ApplyChanges: hosts should not be empty",
+		},
+		{
+			name:     "hostname error",
+			provider: &providertest.Provider{Fqdn: "abcdef.lb.rancher.cloud", Hosts: []string{"203.0.113.1"}, HostnameErr: errors.New("not under the root domain")},
+			hosts:    []string{"203.0.113.5"},
+			notified: true,
+			action:   notify.ActionUpdate,
+			err:      "failed to publish hostname foo.default.abcdef.lb.rancher.cloud: not under the root domain",
+		},
+		{
+			name:     "skipped",
+			provider: &providertest.Provider{Fqdn: "abcdef.lb.rancher.cloud", ApplyErr: errors.Wrap(providertest.ErrSkipped, "ApplyChanges")},
+			hosts:    []string{"203.0.113.5"},
+		},
+		{
+			name:     "no-op",
+			provider: &providertest.Provider{Fqdn: "abcdef.lb.rancher.cloud", Hosts: []string{"203.0.113.5"}},
+			hosts:    []string{"203.0.113.5"},
+		},
+		{
+			name:     "update",
+			provider: &providertest.Provider{Fqdn: "abcdef.lb.rancher.cloud", Hosts: []string{"203.0.113.1"}},
+			hosts:    []string{"203.0.113.5"},
+			notified: true,
+			action:   notify.ActionUpdate,
+		},
+	}
+
+	for _, test := range tests {
+		NewSyncer(test.provider, notifier).Apply(test.hosts, "foo.default.abcdef.lb.rancher.cloud")
+		if !test.notified {
+			// nothing is sent, so the summary of the next case is the next one received
+			continue
+		}
+
+		select {
+		case s := <-received:
+			if s.Action != test.action || s.Error != test.err {
+				t.Errorf("%s: expected %s with error %q, got %s with error %q", test.name, test.action, test.err, s.Action, s.Error)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: timed out waiting for a notification", test.name)
+		}
+	}
+}
+
+func TestSyncerGuard(t *testing.T) {
+	p := &providertest.Provider{
+		Fqdn:    "abcdef.lb.rancher.cloud",
+		Entered: make(chan struct{}),
+		Release: make(chan struct{}),
+	}
+	s := NewSyncer(p, nil)
+
+	first := make(chan error)
+	go func() {
+		_, err := s.Apply([]string{"203.0.113.5"})
+		first <- err
+	}()
+	<-p.Entered
+
+	if _, err := s.TryApply([]string{"203.0.113.9"}); err != ErrApplyRunning {
+		t.Fatalf("expected ErrApplyRunning while an apply is running, got %v", err)
+	}
+
+	// Apply waits for the running apply instead of failing
+	second := make(chan error)
+	go func() {
+		_, err := s.Apply([]string{"203.0.113.9"})
+		second <- err
+	}()
+	select {
+	case err := <-second:
+		t.Fatalf("expected the second apply to wait, it returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(p.Release)
+	if err := <-first; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-p.Entered
+	if err := <-second; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := [][]string{{"203.0.113.5"}, {"203.0.113.9"}}; !reflect.DeepEqual(p.Applied, expected) {
+		t.Errorf("expected the applies to run one after the other as %v, got %v", expected, p.Applied)
+	}
+}
@@ -7,8 +7,6 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
-	"reflect"
-	"sort"
 	"time"
 
 	"github.com/niusmallnan/kube-rdns/controller/k8s"
@@ -20,6 +18,9 @@ import (
 )
 
 const (
+	// Name is the name of the rdns provider
+	Name = "rdns"
+
 	contentType     = "Content-Type"
 	jsonContentType = "application/json"
 	maxHost         = 10
@@ -36,8 +37,9 @@ func jsonBody(payload interface{}) (io.Reader, error) {
 
 type Client struct {
 	httpClient *http.Client
-	kubeClient *kubernetes.Clientset
+	kubeClient kubernetes.Interface
 	base       string
+	breaker    *breaker
 }
 
 func (c *Client) request(method string, url string, body io.Reader) (*http.Request, error) {
@@ -52,13 +54,24 @@ func (c *Client) request(method string, url string, body io.Reader) (*http.Reque
 
 func (c *Client) do(req *http.Request) (model.Response, error) {
 	var data model.Response
+	if !c.breaker.allow() {
+		return data, ErrCircuitOpen
+	}
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		c.breaker.failure()
 		return data, err
 	}
 	// when err is nil, resp contains a non-nil resp.Body which must be closed
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= 500 {
+		c.breaker.failure()
+	} else {
+		c.breaker.success()
+	}
+
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return data, errors.Wrap(err, "Read response body error")
@@ -78,35 +91,45 @@ func (c *Client) do(req *http.Request) (model.Response, error) {
 	return data, nil
 }
 
-func (c *Client) ApplyDomain(hosts []string) error {
-	if len(hosts) == 0 {
-		return errors.New("ApplyDomain: hosts should not be empty")
+func (c *Client) GetName() string {
+	return Name
+}
+
+func (c *Client) GetRootFqdn() string {
+	_, fqdn := k8s.GetTokenAndRootFqdn(c.kubeClient)
+	return fqdn
+}
+
+func (c *Client) Records() ([]string, error) {
+	fqdn := c.GetRootFqdn()
+	if fqdn == "" {
+		return nil, nil
 	}
 
-	if len(hosts) > maxHost {
-		logrus.Debugf("hosts number is %d, over %d", len(hosts), maxHost)
-		hosts = hosts[:maxHost]
+	d, err := c.getDomain(fqdn)
+	if err != nil {
+		return nil, err
 	}
 
+	return d.Hosts, nil
+}
+
+func (c *Client) MaxHosts() int {
+	return maxHost
+}
+
+// ApplyChanges creates the domain when there is no token yet, otherwise it updates its hosts
+func (c *Client) ApplyChanges(hosts []string) error {
 	token, fqdn := k8s.GetTokenAndRootFqdn(c.kubeClient)
 	if fqdn == "" || token == "" {
-		logrus.Debugf("Fqdn for %s has not been exist, need to create a new one", hosts)
 		return c.createDomain(hosts)
-
-	}
-	d, err := c.getDomain(fqdn)
-	if err != nil {
-		return err
 	}
 
-	sort.Strings(d.Hosts)
-	sort.Strings(hosts)
-	if !reflect.DeepEqual(d.Hosts, hosts) {
-		logrus.Debugf("Fqdn %s has some changes, need to update", fqdn)
-		return c.updateDomain(token, fqdn, hosts)
-	}
-	logrus.Debugf("Fqdn %s has no changes, no need to update", fqdn)
+	return c.updateDomain(token, fqdn, hosts)
+}
 
+// ApplyHostname is a no-op, hostnames under the root domain are served by the rdns wildcard record
+func (c *Client) ApplyHostname(hostname string) error {
 	return nil
 }
 
@@ -169,34 +192,35 @@ func (c *Client) updateDomain(token, fqdn string, hosts []string) error {
 	return err
 }
 
-func (c *Client) RenewDomain() error {
+func (c *Client) Renew() error {
 	token, fqdn := k8s.GetTokenAndRootFqdn(c.kubeClient)
 	if token == "" || fqdn == "" {
-		return errors.New("RenewDomain: failed to get token and fqdn")
+		return errors.New("Renew: failed to get token and fqdn")
 	}
 
 	url := fmt.Sprintf("%s/domain/%s/renew", c.base, fqdn)
 
 	req, err := c.request(http.MethodPut, url, nil)
 	if err != nil {
-		return errors.Wrap(err, "RenewDomain: failed to build a request")
+		return errors.Wrap(err, "Renew: failed to build a request")
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 
 	_, err = c.do(req)
 	if err != nil {
-		return errors.Wrap(err, "RenewDomain: failed to execute a request")
+		return errors.Wrap(err, "Renew: failed to execute a request")
 	}
 
 	return err
 }
 
-func NewClient(kubeClient *kubernetes.Clientset) *Client {
+func NewClient(kubeClient kubernetes.Interface) *Client {
 	httpClient := &http.Client{Timeout: 5 * time.Second}
 	return &Client{
 		httpClient: httpClient,
 		kubeClient: kubeClient,
 		base:       setting.GetBaseRdnsURL(),
+		breaker:    newBreaker(setting.GetBreakerThreshold(), setting.GetBreakerCooldown()),
 	}
 }
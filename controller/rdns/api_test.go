@@ -0,0 +1,216 @@
+package rdns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/niusmallnan/rdns-server/model"
+	"github.com/pkg/errors"
+	k8scorev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	testFqdn  = "abcdef.lb.rancher.cloud"
+	testToken = "token"
+)
+
+type request struct {
+	method string
+	path   string
+	auth   string
+	hosts  []string
+}
+
+// fakeServer records the requests to the rdns server and answers them with code and response
+type fakeServer struct {
+	sync.Mutex
+	code     int
+	response model.Response
+	requests []request
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	r := request{method: req.Method, path: req.URL.Path, auth: req.Header.Get("Authorization")}
+	var opts model.DomainOptions
+	if json.NewDecoder(req.Body).Decode(&opts) == nil {
+		r.hosts = opts.Hosts
+	}
+	f.requests = append(f.requests, r)
+
+	w.WriteHeader(f.code)
+	json.NewEncoder(w).Encode(&f.response)
+}
+
+func tokenSecret() *k8scorev1.Secret {
+	return &k8scorev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "rdns-token", Namespace: metav1.NamespaceSystem},
+		Data:       map[string][]byte{"token": []byte(testToken), "fqdn": []byte(testFqdn)},
+	}
+}
+
+func newTestClient(f *fakeServer, threshold int, objects ...runtime.Object) (*Client, *fake.Clientset, func()) {
+	srv := httptest.NewServer(f)
+	kubeClient := fake.NewSimpleClientset(objects...)
+	return &Client{
+		httpClient: http.DefaultClient,
+		kubeClient: kubeClient,
+		base:       srv.URL,
+		breaker:    newBreaker(threshold, testCooldown),
+	}, kubeClient, srv.Close
+}
+
+func TestApplyChangesCreatesDomain(t *testing.T) {
+	f := &fakeServer{
+		code:     http.StatusOK,
+		response: model.Response{Status: http.StatusOK, Token: testToken, Data: model.Domain{Fqdn: testFqdn}},
+	}
+	c, kubeClient, stop := newTestClient(f, 0)
+	defer stop()
+
+	if err := c.ApplyChanges([]string{"203.0.113.5"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []request{{method: http.MethodPost, path: "/domain", hosts: []string{"203.0.113.5"}}}
+	if !reflect.DeepEqual(f.requests, expected) {
+		t.Errorf("expected requests %+v, got %+v", expected, f.requests)
+	}
+
+	secret, err := kubeClient.CoreV1().Secrets(metav1.NamespaceSystem).Get("rdns-token", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the token to be saved: %v", err)
+	}
+	if secret.StringData["token"] != testToken || secret.StringData["fqdn"] != testFqdn {
+		t.Errorf("expected token %s and fqdn %s to be saved, got %v", testToken, testFqdn, secret.StringData)
+	}
+}
+
+func TestApplyChangesUpdatesDomain(t *testing.T) {
+	f := &fakeServer{code: http.StatusOK, response: model.Response{Status: http.StatusOK}}
+	c, _, stop := newTestClient(f, 0, tokenSecret())
+	defer stop()
+
+	if err := c.ApplyChanges([]string{"203.0.113.5", "203.0.113.9"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []request{{
+		method: http.MethodPut,
+		path:   "/domain/" + testFqdn,
+		auth:   "Bearer " + testToken,
+		hosts:  []string{"203.0.113.5", "203.0.113.9"},
+	}}
+	if !reflect.DeepEqual(f.requests, expected) {
+		t.Errorf("expected requests %+v, got %+v", expected, f.requests)
+	}
+}
+
+func TestRecords(t *testing.T) {
+	f := &fakeServer{
+		code:     http.StatusOK,
+		response: model.Response{Status: http.StatusOK, Data: model.Domain{Fqdn: testFqdn, Hosts: []string{"203.0.113.5"}}},
+	}
+
+	// without a saved fqdn there is no domain to ask for
+	c, _, stop := newTestClient(f, 0)
+	hosts, err := c.Records()
+	stop()
+	if err != nil || hosts != nil || len(f.requests) != 0 {
+		t.Fatalf("expected no records and no requests without a domain, got %v, %v and %+v", hosts, err, f.requests)
+	}
+
+	c, _, stop = newTestClient(f, 0, tokenSecret())
+	defer stop()
+	if fqdn := c.GetRootFqdn(); fqdn != testFqdn {
+		t.Errorf("expected root fqdn %s, got %s", testFqdn, fqdn)
+	}
+	hosts, err = c.Records()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"203.0.113.5"}; !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("expected records %v, got %v", expected, hosts)
+	}
+	if expected := (request{method: http.MethodGet, path: "/domain/" + testFqdn}); !reflect.DeepEqual(f.requests, []request{expected}) {
+		t.Errorf("expected request %+v, got %+v", expected, f.requests)
+	}
+}
+
+func TestRenew(t *testing.T) {
+	f := &fakeServer{code: http.StatusOK, response: model.Response{Status: http.StatusOK}}
+	c, _, stop := newTestClient(f, 0)
+	if err := c.Renew(); err == nil {
+		t.Error("expected an error without a token")
+	}
+	stop()
+
+	c, _, stop = newTestClient(f, 0, tokenSecret())
+	defer stop()
+	if err := c.Renew(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []request{{method: http.MethodPut, path: "/domain/" + testFqdn + "/renew", auth: "Bearer " + testToken}}
+	if !reflect.DeepEqual(f.requests, expected) {
+		t.Errorf("expected requests %+v, got %+v", expected, f.requests)
+	}
+}
+
+func TestDoCountsServerErrors(t *testing.T) {
+	f := &fakeServer{code: http.StatusServiceUnavailable, response: model.Response{Message: "unavailable"}}
+	c, _, stop := newTestClient(f, 2, tokenSecret())
+	defer stop()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Records(); err == nil || errors.Cause(err) == ErrCircuitOpen {
+			t.Fatalf("call %d: expected the server error, got %v", i, err)
+		}
+	}
+	checkState(t, c.breaker, breakerOpen)
+
+	if _, err := c.Records(); errors.Cause(err) != ErrCircuitOpen {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if len(f.requests) != 2 {
+		t.Errorf("expected the open breaker to keep the call from the server, got %d requests", len(f.requests))
+	}
+}
+
+func TestDoCountsTransportErrors(t *testing.T) {
+	c, _, stop := newTestClient(&fakeServer{}, 2, tokenSecret())
+	// nothing listens on the url any more
+	stop()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Records(); err == nil || errors.Cause(err) == ErrCircuitOpen {
+			t.Fatalf("call %d: expected a transport error, got %v", i, err)
+		}
+	}
+	checkState(t, c.breaker, breakerOpen)
+}
+
+func TestDoClientErrorsKeepBreakerClosed(t *testing.T) {
+	f := &fakeServer{code: http.StatusNotFound, response: model.Response{Message: "not found"}}
+	c, _, stop := newTestClient(f, 2, tokenSecret())
+	defer stop()
+
+	// a 4xx is an answer of a healthy server
+	for i := 0; i < 5; i++ {
+		if _, err := c.Records(); err == nil || errors.Cause(err) == ErrCircuitOpen {
+			t.Fatalf("call %d: expected the request error, got %v", i, err)
+		}
+	}
+	checkState(t, c.breaker, breakerClosed)
+	if len(f.requests) != 5 {
+		t.Errorf("expected every call to reach the server, got %d requests", len(f.requests))
+	}
+}
@@ -0,0 +1,110 @@
+package rdns
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	breakerClosed = "closed"
+	breakerOpen   = "open"
+	breakerHalf   = "half-open"
+)
+
+// ErrCircuitOpen is returned when calls to the rdns server are short-circuited
+var ErrCircuitOpen error = circuitOpenError{}
+
+type circuitOpenError struct{}
+
+func (circuitOpenError) Error() string {
+	return "rdns server circuit breaker is open"
+}
+
+// Skipped reports that the call was never sent, see provider.IsSkipped
+func (circuitOpenError) Skipped() bool {
+	return true
+}
+
+// breaker opens after threshold consecutive failures, rejects calls for the cooldown,
+// then lets a single trial call through to test whether the rdns server has recovered
+type breaker struct {
+	sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	state     string
+	openedAt  time.Time
+	trial     bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     breakerClosed,
+	}
+}
+
+func (b *breaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setState(breakerHalf)
+		fallthrough
+	case breakerHalf:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+	}
+
+	return true
+}
+
+func (b *breaker) success() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.failures = 0
+	b.trial = false
+	b.setState(breakerClosed)
+}
+
+func (b *breaker) failure() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.failures++
+	b.trial = false
+	if b.state == breakerHalf || b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		b.setState(breakerOpen)
+	}
+}
+
+func (b *breaker) setState(state string) {
+	if b.state == state {
+		return
+	}
+	logrus.Infof("RDNS circuit breaker state changed from %s to %s", b.state, state)
+	b.state = state
+}
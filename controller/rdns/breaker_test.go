@@ -0,0 +1,115 @@
+package rdns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const testCooldown = 20 * time.Millisecond
+
+func checkState(t *testing.T, b *breaker, state string) {
+	if b.state != state {
+		t.Fatalf("expected breaker state %s, got %s", state, b.state)
+	}
+}
+
+func openBreaker(t *testing.T, threshold int) *breaker {
+	b := newBreaker(threshold, testCooldown)
+	for i := 0; i < threshold; i++ {
+		if !b.allow() {
+			t.Fatalf("expected call %d to be allowed before the threshold", i)
+		}
+		checkState(t, b, breakerClosed)
+		b.failure()
+	}
+	checkState(t, b, breakerOpen)
+	return b
+}
+
+func TestBreakerOpensAtThreshold(t *testing.T) {
+	b := openBreaker(t, 3)
+	if b.allow() {
+		t.Fatal("expected an open breaker to reject calls during the cooldown")
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b := newBreaker(2, testCooldown)
+	b.failure()
+	b.success()
+	b.failure()
+	checkState(t, b, breakerClosed)
+	b.failure()
+	checkState(t, b, breakerOpen)
+}
+
+func TestBreakerHalfOpenSingleTrial(t *testing.T) {
+	b := openBreaker(t, 2)
+	time.Sleep(testCooldown)
+
+	if !b.allow() {
+		t.Fatal("expected a trial call after the cooldown")
+	}
+	checkState(t, b, breakerHalf)
+	if b.allow() {
+		t.Fatal("expected a half-open breaker to allow a single trial call")
+	}
+}
+
+func TestBreakerHalfOpenSuccessCloses(t *testing.T) {
+	b := openBreaker(t, 2)
+	time.Sleep(testCooldown)
+
+	if !b.allow() {
+		t.Fatal("expected a trial call after the cooldown")
+	}
+	b.success()
+	checkState(t, b, breakerClosed)
+	if !b.allow() || !b.allow() {
+		t.Fatal("expected a closed breaker to allow calls")
+	}
+}
+
+func TestBreakerHalfOpenFailureReopens(t *testing.T) {
+	b := openBreaker(t, 2)
+	time.Sleep(testCooldown)
+
+	if !b.allow() {
+		t.Fatal("expected a trial call after the cooldown")
+	}
+	// a failed trial opens the breaker again, without waiting for the threshold
+	b.failure()
+	checkState(t, b, breakerOpen)
+	if b.allow() {
+		t.Fatal("expected a reopened breaker to reject calls during the cooldown")
+	}
+
+	time.Sleep(testCooldown)
+	if !b.allow() {
+		t.Fatal("expected a new trial call after the second cooldown")
+	}
+	checkState(t, b, breakerHalf)
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := newBreaker(0, testCooldown)
+	for i := 0; i < 10; i++ {
+		b.failure()
+		if !b.allow() {
+			t.Fatal("expected a disabled breaker to allow every call")
+		}
+	}
+	checkState(t, b, breakerClosed)
+}
+
+func TestCircuitOpenIsSkipped(t *testing.T) {
+	// the same check as provider.IsSkipped, which can not be imported from here
+	skipped, ok := errors.Cause(errors.Wrap(ErrCircuitOpen, "ApplyChanges")).(interface {
+		Skipped() bool
+	})
+	if !ok || !skipped.Skipped() {
+		t.Error("expected a wrapped ErrCircuitOpen to be skipped")
+	}
+}
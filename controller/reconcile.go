@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/niusmallnan/kube-rdns/controller/provider"
+	"github.com/niusmallnan/kube-rdns/setting"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const bearerPrefix = "Bearer "
+
+// ErrNotStarted is returned by Reconcile while the controller waits for its startup delay
+var ErrNotStarted = errors.New("Reconcile: controller is waiting for its startup delay")
+
+// Reconcile applies the current nginx controller ips to the root domain,
+// the result has the hosts which were actually applied and what was done with them
+func (c *RDNSController) Reconcile() (*provider.Result, error) {
+	select {
+	case <-c.started:
+	default:
+		return nil, ErrNotStarted
+	}
+
+	ips, err := c.getNginxControllerIPs()
+	if err != nil {
+		return nil, errors.Wrap(err, "Reconcile: failed to get nginx controller ips")
+	}
+
+	// a reconcile which comes in while the watcher is applying is refused, not queued
+	logrus.Infof("Got the host ips: %s", ips)
+	result, err := c.syncer.TryApply(ips)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// retryAfter returns the Retry-After header value of d, in whole seconds and at least 1
+func retryAfter(d time.Duration) string {
+	seconds := int64((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.FormatInt(seconds, 10)
+}
+
+// ReconcileHandler returns the handler of the reconcile endpoint (POST /reconcile),
+// requests must carry the token as a bearer token
+func (c *RDNSController) ReconcileHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		auth := req.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, bearerPrefix) ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, bearerPrefix)), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		result, err := c.Reconcile()
+		if err == provider.ErrApplyRunning {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err == ErrNotStarted {
+			w.Header().Set("Retry-After", retryAfter(setting.GetStartupDelay()))
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if provider.IsSkipped(err) {
+			// the provider is backing off, e.g. its circuit breaker is open
+			logrus.Debugf("Skip reconcile by request: %v", err)
+			w.Header().Set("Retry-After", retryAfter(setting.GetBreakerCooldown()))
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			logrus.Errorf("Failed to reconcile by request: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			logrus.Errorf("Failed to write reconcile result: %v", err)
+		}
+	})
+}
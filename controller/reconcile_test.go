@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/niusmallnan/kube-rdns/controller/notify"
+	"github.com/niusmallnan/kube-rdns/controller/provider"
+	"github.com/niusmallnan/kube-rdns/controller/provider/providertest"
+	"github.com/niusmallnan/kube-rdns/setting"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+	k8scorev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testToken = "secret"
+
+func newTestController(p *providertest.Provider) *RDNSController {
+	c := newUnstartedController(p, "0s")
+	c.waitStartupDelay()
+	return c
+}
+
+func newUnstartedController(p *providertest.Provider, startupDelay string) *RDNSController {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String("controller-service", "ingress-nginx/ingress-nginx", "")
+	set.String("breaker-cooldown", "90s", "")
+	set.String("startup-delay", startupDelay, "")
+	setting.Init(cli.NewContext(nil, set, nil))
+
+	svc := &k8scorev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "ingress-nginx", Namespace: "ingress-nginx"},
+		Status: k8scorev1.ServiceStatus{
+			LoadBalancer: k8scorev1.LoadBalancerStatus{
+				Ingress: []k8scorev1.LoadBalancerIngress{{IP: "203.0.113.9"}, {IP: "203.0.113.5"}},
+			},
+		},
+	}
+	return NewRDNSController(fake.NewSimpleClientset(svc), p)
+}
+
+func serveReconcile(c *RDNSController, method, auth string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/reconcile", nil)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	w := httptest.NewRecorder()
+	c.ReconcileHandler(testToken).ServeHTTP(w, req)
+	return w
+}
+
+func TestReconcileHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		auth   string
+		code   int
+	}{
+		{"get", http.MethodGet, "Bearer " + testToken, http.StatusMethodNotAllowed},
+		{"no token", http.MethodPost, "", http.StatusUnauthorized},
+		{"bare token", http.MethodPost, testToken, http.StatusUnauthorized},
+		{"basic auth", http.MethodPost, "Basic " + testToken, http.StatusUnauthorized},
+		{"wrong token", http.MethodPost, "Bearer wrong", http.StatusUnauthorized},
+		{"token prefix", http.MethodPost, "Bearer " + testToken[:3], http.StatusUnauthorized},
+		{"valid", http.MethodPost, "Bearer " + testToken, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		p := &providertest.Provider{}
+		w := serveReconcile(newTestController(p), test.method, test.auth)
+		if w.Code != test.code {
+			t.Errorf("%s: expected status %d, got %d: %s", test.name, test.code, w.Code, w.Body.String())
+		}
+		if test.code != http.StatusOK && p.Hosts != nil {
+			t.Errorf("%s: expected nothing to be applied, got %v", test.name, p.Hosts)
+		}
+	}
+}
+
+func TestReconcileHandlerMethodNotAllowed(t *testing.T) {
+	w := serveReconcile(newTestController(&providertest.Provider{}), http.MethodGet, "Bearer "+testToken)
+	if allow := w.Header().Get("Allow"); allow != http.MethodPost {
+		t.Errorf("expected Allow header %s, got %q", http.MethodPost, allow)
+	}
+}
+
+func TestReconcileHandlerResult(t *testing.T) {
+	p := &providertest.Provider{}
+	c := newTestController(p)
+
+	tests := []struct {
+		action string
+		hosts  []string
+	}{
+		{notify.ActionCreate, []string{"203.0.113.5", "203.0.113.9"}},
+		{notify.ActionNone, []string{"203.0.113.5", "203.0.113.9"}},
+	}
+
+	for _, test := range tests {
+		w := serveReconcile(c, http.MethodPost, "Bearer "+testToken)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("expected a json response, got %q", contentType)
+		}
+
+		var result provider.Result
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		expected := provider.Result{Action: test.action, Fqdn: providertest.CreatedFqdn, Hosts: test.hosts}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("expected %+v, got %+v", expected, result)
+		}
+	}
+}
+
+func TestReconcileHandlerError(t *testing.T) {
+	p := &providertest.Provider{ApplyErr: errors.New("rdns server error")}
+	w := serveReconcile(newTestController(p), http.MethodPost, "Bearer "+testToken)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestReconcileHandlerSkipped(t *testing.T) {
+	p := &providertest.Provider{ApplyErr: errors.Wrap(providertest.ErrSkipped, "ApplyChanges")}
+	w := serveReconcile(newTestController(p), http.MethodPost, "Bearer "+testToken)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d: %s", w.Code, w.Body.String())
+	}
+	if retry := w.Header().Get("Retry-After"); retry != "90" {
+		t.Errorf("expected Retry-After of the breaker cooldown, got %q", retry)
+	}
+}
+
+func TestReconcileHandlerNotStarted(t *testing.T) {
+	p := &providertest.Provider{}
+	c := newUnstartedController(p, "200ms")
+
+	delayed := make(chan struct{})
+	go func() {
+		c.waitStartupDelay()
+		close(delayed)
+	}()
+
+	w := serveReconcile(c, http.MethodPost, "Bearer "+testToken)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 during the startup delay, got %d: %s", w.Code, w.Body.String())
+	}
+	if retry := w.Header().Get("Retry-After"); retry != "1" {
+		t.Errorf("expected Retry-After of the startup delay, got %q", retry)
+	}
+	if p.Applied != nil {
+		t.Errorf("expected nothing to be applied during the startup delay, got %v", p.Applied)
+	}
+
+	select {
+	case <-delayed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the startup delay")
+	}
+	if w := serveReconcile(c, http.MethodPost, "Bearer "+testToken); w.Code != http.StatusOK {
+		t.Errorf("expected status 200 after the startup delay, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		d     time.Duration
+		value string
+	}{
+		{0, "1"},
+		{500 * time.Millisecond, "1"},
+		{time.Minute, "60"},
+		{time.Minute + time.Millisecond, "61"},
+	}
+
+	for _, test := range tests {
+		if value := retryAfter(test.d); value != test.value {
+			t.Errorf("%s: expected %s, got %s", test.d, test.value, value)
+		}
+	}
+}
+
+func TestReconcileHandlerConflict(t *testing.T) {
+	p := &providertest.Provider{Entered: make(chan struct{}), Release: make(chan struct{})}
+	c := newTestController(p)
+
+	// an apply of the ingress watcher is still running
+	done := make(chan error)
+	go func() {
+		_, err := c.syncer.Apply([]string{"203.0.113.1"})
+		done <- err
+	}()
+	<-p.Entered
+
+	w := serveReconcile(c, http.MethodPost, "Bearer "+testToken)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status 409, got %d: %s", w.Code, w.Body.String())
+	}
+
+	close(p.Release)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p.Entered = nil
+	if w := serveReconcile(c, http.MethodPost, "Bearer "+testToken); w.Code != http.StatusOK {
+		t.Errorf("expected status 200 once the apply is done, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// nonGlobalCIDRs are the special-use ranges which are not routable on the public internet
+var nonGlobalCIDRs = mustParseCIDRs(
+	// IPv4
+	"0.0.0.0/8",       // "this" network
+	"10.0.0.0/8",      // private
+	"100.64.0.0/10",   // carrier-grade NAT
+	"127.0.0.0/8",     // loopback
+	"169.254.0.0/16",  // link local
+	"172.16.0.0/12",   // private
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // documentation (TEST-NET-1)
+	"192.168.0.0/16",  // private
+	"198.18.0.0/15",   // benchmarking
+	"198.51.100.0/24", // documentation (TEST-NET-2)
+	"203.0.113.0/24",  // documentation (TEST-NET-3)
+	"224.0.0.0/4",     // multicast
+	"240.0.0.0/4",     // reserved, includes limited broadcast
+	// IPv6
+	"::/128",         // unspecified
+	"::1/128",        // loopback
+	"64:ff9b:1::/48", // local-use IPv4/IPv6 translation
+	"100::/64",       // discard only
+	"2001::/23",      // IETF protocol assignments
+	"2001:2::/48",    // benchmarking
+	"2001:db8::/32",  // documentation
+	"3fff::/20",      // documentation
+	"5f00::/16",      // segment routing (SRv6) SIDs
+	"fc00::/7",       // unique local
+	"fe80::/10",      // link local
+	"ff00::/8",       // multicast
+)
+
+// globalCIDRs are the parts of nonGlobalCIDRs which the IANA special-purpose registries
+// list as globally reachable, they are checked first
+var globalCIDRs = mustParseCIDRs(
+	// IPv4
+	"192.0.0.9/32",  // port control protocol anycast
+	"192.0.0.10/32", // traversal using relays around NAT anycast
+	// IPv6
+	"2001:1::1/128",   // port control protocol anycast
+	"2001:1::2/128",   // traversal using relays around NAT anycast
+	"2001:3::/32",     // AMT
+	"2001:4:112::/48", // AS112-v6
+	"2001:20::/28",    // ORCHIDv2
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// IsGloballyRoutable returns true if ip is not in any of the private or special-use ranges,
+// or is in one of their globally reachable exceptions
+func IsGloballyRoutable(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, n := range globalCIDRs {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	for _, n := range nonGlobalCIDRs {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsValidHostIP returns false for values which can not be a real host address,
+// such as the placeholders some ingress controllers set before the lb ip is ready
+func IsValidHostIP(s string) bool {
+	ip := net.ParseIP(s)
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() {
+		return false
+	}
+	return true
+}
+
+// NormalizeIP returns the canonical form of an ip address,
+// IPv4-mapped IPv6 addresses such as ::ffff:203.0.113.5 become plain IPv4
+func NormalizeIP(s string) string {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return s
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String()
+	}
+	return ip.String()
+}
+
+// HostIP validates an ip address collected for the root domain and returns its canonical form,
+// with globalOnly ips which are not globally routable are rejected as well
+func HostIP(s string, globalOnly bool) (string, error) {
+	if !IsValidHostIP(s) {
+		return "", errors.Errorf("invalid ip address %q", s)
+	}
+	if globalOnly && !IsGloballyRoutable(net.ParseIP(s)) {
+		return "", errors.Errorf("ip address %q is not globally routable", s)
+	}
+	return NormalizeIP(s), nil
+}
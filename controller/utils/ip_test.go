@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsGloballyRoutable(t *testing.T) {
+	tests := []struct {
+		ip     string
+		global bool
+	}{
+		{"8.8.8.8", true},
+		{"1.1.1.1", true},
+		{"10.0.0.1", false},
+		{"100.64.0.1", false},
+		{"127.0.0.1", false},
+		{"169.254.1.1", false},
+		{"172.16.0.1", false},
+		{"172.32.0.1", true},
+		{"192.0.0.8", false},
+		{"192.0.0.9", true},
+		{"192.0.0.10", true},
+		{"192.0.0.11", false},
+		{"192.0.2.1", false},
+		{"192.168.1.1", false},
+		{"198.18.0.1", false},
+		{"203.0.113.5", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:8.8.8.8", true},
+		{"2606:4700::1111", true},
+		{"2400:cb00::1", true},
+		{"::", false},
+		{"::1", false},
+		{"64:ff9b:1::1", false},
+		{"100::1", false},
+		{"2001::1", false},
+		{"2001:1::1", true},
+		{"2001:1::2", true},
+		{"2001:1::3", false},
+		{"2001:3::1", true},
+		{"2001:4:112::1", true},
+		{"2001:4:113::1", false},
+		{"2001:20::1", true},
+		{"2001:2f::1", true},
+		{"2001:30::1", false},
+		{"2001:1ff::1", false},
+		{"2001:200::1", true},
+		{"2001:2::1", false},
+		{"2001:db8::1", false},
+		{"3fff::1", false},
+		{"5f00::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"ff02::1", false},
+	}
+
+	for _, test := range tests {
+		if got := IsGloballyRoutable(net.ParseIP(test.ip)); got != test.global {
+			t.Errorf("%s: expected globally routable %v, got %v", test.ip, test.global, got)
+		}
+	}
+
+	if IsGloballyRoutable(nil) {
+		t.Error("expected a nil ip not to be globally routable")
+	}
+}
+
+func TestIsValidHostIP(t *testing.T) {
+	tests := []struct {
+		ip    string
+		valid bool
+	}{
+		{"<pending>", false},
+		{"", false},
+		{"localhost", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"127.0.0.1", false},
+		{"127.1.2.3", false},
+		{"::1", false},
+		{"10.0.0.1", true},
+		{"203.0.113.5", true},
+		{"::ffff:203.0.113.5", true},
+		{"2001:db8::1", true},
+	}
+
+	for _, test := range tests {
+		if got := IsValidHostIP(test.ip); got != test.valid {
+			t.Errorf("%q: expected valid %v, got %v", test.ip, test.valid, got)
+		}
+	}
+}
+
+func TestNormalizeIP(t *testing.T) {
+	tests := []struct {
+		ip         string
+		normalized string
+	}{
+		{"::ffff:203.0.113.5", "203.0.113.5"},
+		{"203.0.113.5", "203.0.113.5"},
+		{"2001:DB8:0:0::1", "2001:db8::1"},
+		{"<pending>", "<pending>"},
+	}
+
+	for _, test := range tests {
+		if got := NormalizeIP(test.ip); got != test.normalized {
+			t.Errorf("%q: expected %q, got %q", test.ip, test.normalized, got)
+		}
+	}
+}
+
+func TestHostIP(t *testing.T) {
+	tests := []struct {
+		ip         string
+		globalOnly bool
+		host       string
+		err        bool
+	}{
+		{"<pending>", false, "", true},
+		{"0.0.0.0", false, "", true},
+		{"10.0.0.1", false, "10.0.0.1", false},
+		{"10.0.0.1", true, "", true},
+		{"::ffff:8.8.8.8", true, "8.8.8.8", false},
+		{"::ffff:192.168.1.1", true, "", true},
+		{"2001:2::1", true, "", true},
+	}
+
+	for _, test := range tests {
+		host, err := HostIP(test.ip, test.globalOnly)
+		if (err != nil) != test.err {
+			t.Errorf("%q (global only %v): expected error %v, got %v", test.ip, test.globalOnly, test.err, err)
+		}
+		if host != test.host {
+			t.Errorf("%q (global only %v): expected %q, got %q", test.ip, test.globalOnly, test.host, host)
+		}
+	}
+}
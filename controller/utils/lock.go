@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// FileLock is an exclusive flock on a file
+type FileLock struct {
+	file *os.File
+}
+
+// AcquireFileLock takes the lock without blocking, it fails if another process holds it
+func AcquireFileLock(path string) (*FileLock, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, errors.Errorf("lock file %s is held by another process", path)
+		}
+		return nil, err
+	}
+
+	return &FileLock{file: f}, nil
+}
+
+// Release unlocks and closes the lock file
+func (l *FileLock) Release() error {
+	defer l.file.Close()
+	return syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAcquireFileLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube-rdns-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "kube-rdns.lock")
+
+	lock, err := AcquireFileLock(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// flock locks belong to the open file, so a second open conflicts even in the same process
+	if _, err := AcquireFileLock(path); err == nil {
+		t.Fatal("expected a second lock on the same file to fail")
+	} else if expected := fmt.Sprintf("lock file %s is held by another process", path); err.Error() != expected {
+		t.Fatalf("expected error %q, got %q", expected, err.Error())
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("unexpected error on release: %v", err)
+	}
+
+	lock, err = AcquireFileLock(path)
+	if err != nil {
+		t.Fatalf("expected the lock to be free after release, got %v", err)
+	}
+	lock.Release()
+}
@@ -3,9 +3,11 @@ package watch
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/niusmallnan/kube-rdns/controller/k8s"
-	"github.com/niusmallnan/kube-rdns/controller/rdns"
+	"github.com/niusmallnan/kube-rdns/controller/provider"
+	"github.com/niusmallnan/kube-rdns/controller/utils"
 	"github.com/niusmallnan/kube-rdns/setting"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -13,19 +15,32 @@ import (
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/client-go/util/workqueue"
 )
 
-func NewIngressResource(kubeClient *kubernetes.Clientset, rdnsClient *rdns.Client) *IngressResource {
+func NewIngressResource(kubeClient kubernetes.Interface, syncer *provider.Syncer) *IngressResource {
 	queue := workqueue.New()
 	stop := make(chan struct{})
-	return &IngressResource{rdnsClient, kubeClient, queue, stop}
+	return &IngressResource{
+		syncer:     syncer,
+		kubeClient: kubeClient,
+		queue:      queue,
+		stop:       stop,
+		now:        time.Now,
+	}
 }
 
 func (n *IngressResource) ignore(ing *extensionsv1beta1.Ingress) bool {
+	// ingress which is still young may change its lb ip a few times,
+	// it will be picked up again by the resync once it gets old enough
+	if age := n.now().Sub(ing.CreationTimestamp.Time); age < setting.GetIngressMinAge() {
+		logrus.Debugf("Ingress resource /%s/%s is younger than %s, skip it", ing.Namespace, ing.Name, setting.GetIngressMinAge().String())
+		return true
+	}
 	if ing.Annotations == nil {
 		return false
 	}
@@ -36,25 +51,43 @@ func (n *IngressResource) ignore(ing *extensionsv1beta1.Ingress) bool {
 }
 
 func (n *IngressResource) getRdnsHostname(ing *extensionsv1beta1.Ingress) string {
-	_, rootFqdn := k8s.GetTokenAndRootFqdn(n.kubeClient)
-	return fmt.Sprintf("%s.%s.%s", ing.Name, ing.Namespace, rootFqdn)
+	return fmt.Sprintf("%s.%s.%s", ing.Name, ing.Namespace, n.syncer.GetRootFqdn())
 }
 
 func (n *IngressResource) getIngressIps(ing *extensionsv1beta1.Ingress) []string {
 	var ips []string
 	for _, i := range ing.Status.LoadBalancer.Ingress {
-		if i.IP != "" {
-			ips = append(ips, i.IP)
+		ip, err := utils.HostIP(i.IP, setting.IsGlobalIPsOnly())
+		if err != nil {
+			logrus.Debugf("Ingress resource /%s/%s: skip %v", ing.Namespace, ing.Name, err)
+			continue
 		}
+		ips = append(ips, ip)
 	}
 	logrus.Debugf("Got ingress resource ip addresses: %s", ips)
 
 	return ips
 }
 
+func isNginxClass(ing *extensionsv1beta1.Ingress) bool {
+	class := ing.Annotations[annotationIngressClass]
+	return class == "" || class == ingressClassNginx // nginx as default
+}
+
 func (n *IngressResource) sync(ing *extensionsv1beta1.Ingress) {
 	fqdn := n.getRdnsHostname(ing)
 
+	// the controller service is looked up once per sync, not on every retry,
+	// and only for ingresses which are served by the nginx controller
+	var controllerIps []string
+	if svc := setting.GetControllerService(); svc != "" && isNginxClass(ing) {
+		var err error
+		if controllerIps, err = k8s.GetServiceIPs(n.kubeClient, svc); err != nil {
+			logrus.Errorf("Failed to get ips of controller service %s: %v", svc, err)
+			return
+		}
+	}
+
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		// Retrieve the latest version of Ingress before attempting update
 		// RetryOnConflict uses exponential backoff to avoid exhausting the apiserver
@@ -74,11 +107,18 @@ func (n *IngressResource) sync(ing *extensionsv1beta1.Ingress) {
 		case "": // nginx as default
 			fallthrough
 		case ingressClassNginx:
-			ips := n.getIngressIps(latestIng)
+			ips := controllerIps
+			if setting.GetControllerService() == "" {
+				ips = n.getIngressIps(latestIng)
+			}
 			if len(ips) > 0 {
 				changed = true
-				if err := n.rdnsClient.ApplyDomain(ips); err == nil {
+				_, err := n.syncer.Apply(ips, fqdn)
+				if err == nil {
 					latestIng.Annotations[annotationHostname] = fqdn
+				} else if provider.IsSkipped(err) {
+					logrus.Debugf("Skip ingress resource /%s/%s: %v", latestIng.Namespace, latestIng.Name, err)
+					return err
 				} else {
 					logrus.Error(errors.Wrap(err, "Called by ingress watch"))
 					return err
@@ -108,7 +148,7 @@ func (n *IngressResource) sync(ing *extensionsv1beta1.Ingress) {
 		return err
 	})
 
-	if retryErr != nil {
+	if retryErr != nil && !provider.IsSkipped(retryErr) {
 		logrus.Errorf("Failed to retry to update ingress resource: %v", retryErr)
 	}
 }
@@ -117,6 +157,10 @@ func (n *IngressResource) WatchResources() {
 	defer close(n.stop)
 
 	watcher := cache.NewListWatchFromClient(n.kubeClient.ExtensionsV1beta1().RESTClient(), "ingresses", v1.NamespaceAll, fields.Everything())
+	// list in chunks when --list-chunk-size is set, large clusters may exceed the response size limit with a single list
+	watcher.ListFunc = func(options metav1.ListOptions) (runtime.Object, error) {
+		return k8s.ListIngresses(n.kubeClient, v1.NamespaceAll, options)
+	}
 
 	_, wc := cache.NewInformer(watcher,
 		&extensionsv1beta1.Ingress{},
@@ -138,21 +182,30 @@ func (n *IngressResource) WatchResources() {
 			},
 		})
 	go wc.Run(n.stop)
-
-	go func() {
-		for {
-			item, quit := n.queue.Get()
-			if quit {
-				return
-			}
-			ing := item.(*extensionsv1beta1.Ingress)
-			logrus.Debugf("Ingress resource /%s/%s: begin processing", ing.Namespace, ing.Name)
-			n.sync(ing)
-			logrus.Debugf("Ingress resource /%s/%s: done processing", ing.Namespace, ing.Name)
-			n.queue.Done(item)
-		}
-	}()
+	go n.runWorker(wc.HasSynced)
 
 	<-n.stop
 	n.queue.ShutDown()
 }
+
+// runWorker syncs the queued ingresses once hasSynced, it returns when the queue is shut down
+func (n *IngressResource) runWorker(hasSynced cache.InformerSynced) {
+	// do not process anything until the informer has the full picture of the ingresses
+	if !cache.WaitForCacheSync(n.stop, hasSynced) {
+		logrus.Error("Failed to wait for ingress caches to sync")
+		return
+	}
+	logrus.Info("Ingress caches synced")
+
+	for {
+		item, quit := n.queue.Get()
+		if quit {
+			return
+		}
+		ing := item.(*extensionsv1beta1.Ingress)
+		logrus.Debugf("Ingress resource /%s/%s: begin processing", ing.Namespace, ing.Name)
+		n.sync(ing)
+		logrus.Debugf("Ingress resource /%s/%s: done processing", ing.Namespace, ing.Name)
+		n.queue.Done(item)
+	}
+}
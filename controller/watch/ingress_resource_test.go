@@ -0,0 +1,193 @@
+package watch
+
+import (
+	"flag"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/niusmallnan/kube-rdns/controller/provider"
+	"github.com/niusmallnan/kube-rdns/controller/provider/providertest"
+	"github.com/niusmallnan/kube-rdns/setting"
+	"github.com/urfave/cli"
+	k8scorev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func initSettings(values map[string]string) {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for name, value := range values {
+		set.String(name, value, "")
+	}
+	setting.Init(cli.NewContext(nil, set, nil))
+}
+
+func TestIgnoreYoungIngress(t *testing.T) {
+	initSettings(map[string]string{"ingress-min-age": "5m"})
+
+	now := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	n := &IngressResource{now: func() time.Time { return now }}
+
+	tests := []struct {
+		name     string
+		age      time.Duration
+		hostname string
+		ignore   bool
+	}{
+		{name: "young", age: time.Minute, ignore: true},
+		{name: "just below min age", age: 5*time.Minute - time.Second, ignore: true},
+		{name: "at min age", age: 5 * time.Minute, ignore: false},
+		{name: "old", age: time.Hour, ignore: false},
+		{name: "old with hostname", age: time.Hour, hostname: "foo.default.example.com", ignore: true},
+	}
+
+	for _, test := range tests {
+		ing := &extensionsv1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "foo",
+				Namespace:         "default",
+				CreationTimestamp: metav1.NewTime(now.Add(-test.age)),
+			},
+		}
+		if test.hostname != "" {
+			ing.Annotations = map[string]string{annotationHostname: test.hostname}
+		}
+		if got := n.ignore(ing); got != test.ignore {
+			t.Errorf("%s: expected ignore %v, got %v", test.name, test.ignore, got)
+		}
+	}
+}
+
+func TestIgnoreWithoutMinAge(t *testing.T) {
+	initSettings(map[string]string{})
+
+	now := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	n := &IngressResource{now: func() time.Time { return now }}
+
+	ing := &extensionsv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "foo",
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(now),
+		},
+	}
+	if n.ignore(ing) {
+		t.Error("expected a new ingress not to be ignored without a min age")
+	}
+}
+
+func newIngress(class string) *extensionsv1beta1.Ingress {
+	ing := &extensionsv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "foo",
+			Namespace:   "default",
+			Annotations: map[string]string{},
+		},
+	}
+	if class != "" {
+		ing.Annotations[annotationIngressClass] = class
+	}
+	return ing
+}
+
+func TestSyncWithControllerService(t *testing.T) {
+	initSettings(map[string]string{"controller-service": "ingress-nginx/ingress-nginx"})
+
+	svc := &k8scorev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "ingress-nginx", Namespace: "ingress-nginx"},
+		Status: k8scorev1.ServiceStatus{
+			LoadBalancer: k8scorev1.LoadBalancerStatus{
+				Ingress: []k8scorev1.LoadBalancerIngress{{IP: "203.0.113.5"}, {IP: "<pending>"}},
+			},
+		},
+	}
+	// the ingress has no status yet, its hosts come from the controller service
+	ing := newIngress("")
+	client := fake.NewSimpleClientset(svc, ing)
+	p := &providertest.Provider{Fqdn: "abcdef.lb.rancher.cloud"}
+	n := NewIngressResource(client, provider.NewSyncer(p, nil))
+
+	n.sync(ing)
+
+	if expected := [][]string{{"203.0.113.5"}}; !reflect.DeepEqual(p.Applied, expected) {
+		t.Fatalf("expected hosts %v to be applied, got %v", expected, p.Applied)
+	}
+	fqdn := "foo.default.abcdef.lb.rancher.cloud"
+	if expected := []string{fqdn}; !reflect.DeepEqual(p.Hostnames, expected) {
+		t.Fatalf("expected hostnames %v to be applied, got %v", expected, p.Hostnames)
+	}
+
+	updated, err := client.ExtensionsV1beta1().Ingresses("default").Get("foo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Annotations[annotationHostname] != fqdn {
+		t.Errorf("expected ingress to be annotated with %s, got %q", fqdn, updated.Annotations[annotationHostname])
+	}
+}
+
+func TestSyncOtherClassSkipsControllerService(t *testing.T) {
+	initSettings(map[string]string{"controller-service": "ingress-nginx/ingress-nginx"})
+
+	// the controller service does not exist, which must not matter for other classes
+	ing := newIngress("gce")
+	client := fake.NewSimpleClientset(ing)
+	p := &providertest.Provider{Fqdn: "abcdef.lb.rancher.cloud"}
+	n := NewIngressResource(client, provider.NewSyncer(p, nil))
+
+	n.sync(ing)
+
+	for _, action := range client.Actions() {
+		if action.GetResource().Resource == "services" {
+			t.Errorf("expected no service lookup for ingress class gce, got %s %s", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+	if len(p.Applied) != 0 || len(p.Hostnames) != 0 {
+		t.Errorf("expected nothing to be applied, got hosts %v and hostnames %v", p.Applied, p.Hostnames)
+	}
+}
+
+func TestWorkerWaitsForCacheSync(t *testing.T) {
+	initSettings(map[string]string{})
+
+	ing := newIngress("")
+	ing.Status.LoadBalancer.Ingress = []k8scorev1.LoadBalancerIngress{{IP: "203.0.113.5"}}
+	p := &providertest.Provider{
+		Fqdn:    "abcdef.lb.rancher.cloud",
+		Entered: make(chan struct{}),
+		Release: make(chan struct{}),
+	}
+	n := NewIngressResource(fake.NewSimpleClientset(ing), provider.NewSyncer(p, nil))
+	n.queue.Add(ing)
+
+	var synced int32
+	done := make(chan struct{})
+	go func() {
+		n.runWorker(func() bool { return atomic.LoadInt32(&synced) == 1 })
+		close(done)
+	}()
+
+	// the informer has not synced, the queued ingress must wait
+	select {
+	case <-p.Entered:
+		t.Fatal("expected no apply before the informer has synced")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	atomic.StoreInt32(&synced, 1)
+	select {
+	case <-p.Entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the apply once the informer has synced")
+	}
+	close(p.Release)
+
+	n.queue.ShutDown()
+	<-done
+	if expected := [][]string{{"203.0.113.5"}}; !reflect.DeepEqual(p.Applied, expected) {
+		t.Errorf("expected hosts %v to be applied, got %v", expected, p.Applied)
+	}
+}
@@ -1,7 +1,9 @@
 package watch
 
 import (
-	"github.com/niusmallnan/kube-rdns/controller/rdns"
+	"time"
+
+	"github.com/niusmallnan/kube-rdns/controller/provider"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/util/workqueue"
 )
@@ -13,8 +15,9 @@ const (
 )
 
 type IngressResource struct {
-	rdnsClient *rdns.Client
-	kubeClient *kubernetes.Clientset
+	syncer     *provider.Syncer
+	kubeClient kubernetes.Interface
 	queue      *workqueue.Type
 	stop       chan struct{}
+	now        func() time.Time
 }
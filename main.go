@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/niusmallnan/kube-rdns/controller"
+	"github.com/niusmallnan/kube-rdns/controller/provider"
+	"github.com/niusmallnan/kube-rdns/controller/utils"
 	"github.com/niusmallnan/kube-rdns/setting"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -54,6 +56,78 @@ func main() {
 			Value:  setting.DefaultIngressResyncDuration,
 			EnvVar: "RANCHER_INGRESS_RESYNC_DURATION",
 		},
+		cli.DurationFlag{
+			Name:   "ingress-min-age",
+			Value:  setting.DefaultIngressMinAge,
+			EnvVar: "RANCHER_INGRESS_MIN_AGE",
+		},
+		cli.IntFlag{
+			Name:   "list-chunk-size",
+			Value:  setting.DefaultListChunkSize,
+			EnvVar: "RANCHER_LIST_CHUNK_SIZE",
+		},
+		cli.IntFlag{
+			Name:   "breaker-threshold",
+			Value:  setting.DefaultBreakerThreshold,
+			EnvVar: "RANCHER_BREAKER_THRESHOLD",
+		},
+		cli.DurationFlag{
+			Name:   "breaker-cooldown",
+			Value:  setting.DefaultBreakerCooldown,
+			EnvVar: "RANCHER_BREAKER_COOLDOWN",
+		},
+		cli.StringFlag{
+			Name:   "reconcile-token",
+			EnvVar: "RANCHER_RECONCILE_TOKEN",
+		},
+		cli.BoolFlag{
+			Name:   "global-ips-only",
+			EnvVar: "RANCHER_GLOBAL_IPS_ONLY",
+		},
+		cli.StringFlag{
+			Name:   "controller-service",
+			EnvVar: "RANCHER_CONTROLLER_SERVICE",
+		},
+		cli.DurationFlag{
+			Name:   "startup-delay",
+			Value:  setting.DefaultStartupDelay,
+			EnvVar: "RANCHER_STARTUP_DELAY",
+		},
+		cli.StringFlag{
+			Name:   "lock-file",
+			EnvVar: "RANCHER_LOCK_FILE",
+		},
+		cli.StringFlag{
+			Name:   "notify-url",
+			EnvVar: "RANCHER_NOTIFY_URL",
+		},
+		cli.BoolFlag{
+			Name:   "notify-slack",
+			EnvVar: "RANCHER_NOTIFY_SLACK",
+		},
+		cli.BoolFlag{
+			Name:   "notify-noop",
+			EnvVar: "RANCHER_NOTIFY_NOOP",
+		},
+		cli.StringFlag{
+			Name:   "provider",
+			Value:  setting.DefaultProvider,
+			EnvVar: "RANCHER_PROVIDER",
+		},
+		cli.StringFlag{
+			Name:   "etcd-endpoint",
+			Value:  setting.DefaultEtcdEndpoint,
+			EnvVar: "RANCHER_ETCD_ENDPOINT",
+		},
+		cli.StringFlag{
+			Name:   "coredns-path-prefix",
+			Value:  setting.DefaultCorednsPathPrefix,
+			EnvVar: "RANCHER_COREDNS_PATH_PREFIX",
+		},
+		cli.StringFlag{
+			Name:   "coredns-domain",
+			EnvVar: "RANCHER_COREDNS_DOMAIN",
+		},
 	}
 	app.Action = func(ctx *cli.Context) {
 		if err := appMain(ctx); err != nil {
@@ -71,16 +145,40 @@ func appMain(ctx *cli.Context) error {
 
 	setting.Init(ctx)
 
+	// skipped young ingresses are only picked up again by the informer resync
+	if setting.GetIngressMinAge() > 0 && setting.GetIngressResyncDuration() == 0 {
+		return errors.New("--ingress-min-age requires a non-zero --ingress-resync-duration")
+	}
+
+	var lock *utils.FileLock
+	if lockFile := ctx.String("lock-file"); lockFile != "" {
+		var err error
+		if lock, err = utils.AcquireFileLock(lockFile); err != nil {
+			return errors.Wrap(err, "Failed to acquire lock file")
+		}
+		logrus.Infof("Acquired lock file %s", lockFile)
+	}
+
 	kubeClient, err := createApiserverClient()
 	if err != nil {
 		handleFatalInitError(err)
 	}
-	c := controller.NewRDNSController(kubeClient)
+	p, err := provider.ByName(ctx.String("provider"), kubeClient)
+	if err != nil {
+		return errors.Wrap(err, "Failed to init provider")
+	}
+	logrus.Infof("Using provider %s", p.GetName())
+	c := controller.NewRDNSController(kubeClient, p)
 
 	mux := http.NewServeMux()
-	go registerHandlers(ctx.String("listen"), c, mux)
+	go registerHandlers(ctx.String("listen"), ctx.String("reconcile-token"), c, mux)
 
 	go handleSigterm(c, func(code int) {
+		if lock != nil {
+			if err := lock.Release(); err != nil {
+				logrus.Errorf("Failed to release lock file: %v", err)
+			}
+		}
 		os.Exit(code)
 	})
 
@@ -130,13 +228,18 @@ func handleSigterm(rdnsc *controller.RDNSController, exit exiter) {
 	exit(exitCode)
 }
 
-func registerHandlers(listen string, rc *controller.RDNSController, mux *http.ServeMux) {
+func registerHandlers(listen, reconcileToken string, rc *controller.RDNSController, mux *http.ServeMux) {
 	// expose health check endpoint (/healthz)
 	healthz.InstallHandler(mux,
 		healthz.PingHealthz,
 		rc,
 	)
 
+	// expose reconcile endpoint (/reconcile) only when a token is configured
+	if reconcileToken != "" {
+		mux.Handle("/reconcile", rc.ReconcileHandler(reconcileToken))
+	}
+
 	// TODO: enable pprof
 
 	server := &http.Server{
@@ -11,6 +11,14 @@ const (
 	DefaultBaseRdnsURL           = "http://api.rdns.rancher.cloud/v1"
 	DefaultRnewDuration          = 24 * time.Hour
 	DefaultIngressResyncDuration = 5 * time.Minute
+	DefaultIngressMinAge         = 0 * time.Second
+	DefaultListChunkSize         = 0
+	DefaultBreakerThreshold      = 5
+	DefaultBreakerCooldown       = 1 * time.Minute
+	DefaultStartupDelay          = 0 * time.Second
+	DefaultProvider              = "rdns"
+	DefaultEtcdEndpoint          = "http://127.0.0.1:2379"
+	DefaultCorednsPathPrefix     = "/skydns"
 )
 
 var (
@@ -18,6 +26,19 @@ var (
 	baseRdnsURL           string
 	renewDuration         time.Duration
 	ingressResyncDuration time.Duration
+	ingressMinAge         time.Duration
+	listChunkSize         int
+	breakerThreshold      int
+	breakerCooldown       time.Duration
+	globalIPsOnly         bool
+	controllerService     string
+	startupDelay          time.Duration
+	notifyURL             string
+	notifySlack           bool
+	notifyNoop            bool
+	etcdEndpoint          string
+	corednsPathPrefix     string
+	corednsDomain         string
 )
 
 func Init(ctx *cli.Context) {
@@ -25,6 +46,19 @@ func Init(ctx *cli.Context) {
 	baseRdnsURL = ctx.String("base-rdns-url")
 	renewDuration = ctx.Duration("renew-duration")
 	ingressResyncDuration = ctx.Duration("ingress-resync-duration")
+	ingressMinAge = ctx.Duration("ingress-min-age")
+	listChunkSize = ctx.Int("list-chunk-size")
+	breakerThreshold = ctx.Int("breaker-threshold")
+	breakerCooldown = ctx.Duration("breaker-cooldown")
+	globalIPsOnly = ctx.Bool("global-ips-only")
+	controllerService = ctx.String("controller-service")
+	startupDelay = ctx.Duration("startup-delay")
+	notifyURL = ctx.String("notify-url")
+	notifySlack = ctx.Bool("notify-slack")
+	notifyNoop = ctx.Bool("notify-noop")
+	etcdEndpoint = ctx.String("etcd-endpoint")
+	corednsPathPrefix = ctx.String("coredns-path-prefix")
+	corednsDomain = ctx.String("coredns-domain")
 }
 
 func GetRootDomain() string {
@@ -42,3 +76,55 @@ func GetRenewDuration() time.Duration {
 func GetIngressResyncDuration() time.Duration {
 	return ingressResyncDuration
 }
+
+func GetIngressMinAge() time.Duration {
+	return ingressMinAge
+}
+
+func GetListChunkSize() int {
+	return listChunkSize
+}
+
+func GetBreakerThreshold() int {
+	return breakerThreshold
+}
+
+func GetBreakerCooldown() time.Duration {
+	return breakerCooldown
+}
+
+func IsGlobalIPsOnly() bool {
+	return globalIPsOnly
+}
+
+func GetControllerService() string {
+	return controllerService
+}
+
+func GetStartupDelay() time.Duration {
+	return startupDelay
+}
+
+func GetNotifyURL() string {
+	return notifyURL
+}
+
+func IsNotifySlack() bool {
+	return notifySlack
+}
+
+func IsNotifyNoop() bool {
+	return notifyNoop
+}
+
+func GetEtcdEndpoint() string {
+	return etcdEndpoint
+}
+
+func GetCorednsPathPrefix() string {
+	return corednsPathPrefix
+}
+
+func GetCorednsDomain() string {
+	return corednsDomain
+}